@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"order-service/internal/auth"
+)
+
+// runAPIKey выпускает новый API-ключ. Ключ печатается один раз, в файл ключей
+// (AUTH_API_KEYS_FILE) добавляется только запись с его хэшем:
+//
+//	order-service apikey -name billing -scopes read,write -role partner -client-ids 42
+func runAPIKey(args []string) {
+	fs := flag.NewFlagSet("apikey", flag.ExitOnError)
+	name := fs.String("name", "", "key name, shown in logs as the subject")
+	scopesFlag := fs.String("scopes", string(auth.ScopeRead), "comma-separated scopes: read, write, admin")
+	role := fs.String("role", "", "data access role: admin, service, support or partner")
+	clientIDsFlag := fs.String("client-ids", "", "comma-separated client IDs for support and partner roles")
+	fs.Parse(args)
+
+	if *name == "" {
+		fatal("-name is required")
+	}
+	if *role == "" {
+		fatal("-role is required")
+	}
+
+	var clientIDs []int64
+	if *clientIDsFlag != "" {
+		for _, s := range strings.Split(*clientIDsFlag, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+			if err != nil {
+				fatal("invalid -client-ids", "error", err)
+			}
+			clientIDs = append(clientIDs, id)
+		}
+	}
+
+	if err := auth.CheckTenancy(auth.Role(*role), clientIDs); err != nil {
+		fatal("invalid -role or -client-ids", "error", err)
+	}
+
+	var scopes []auth.Scope
+	for _, s := range strings.Split(*scopesFlag, ",") {
+		scope, err := auth.ParseScope(strings.TrimSpace(s))
+		if err != nil {
+			fatal("invalid -scopes", "error", err)
+		}
+		scopes = append(scopes, scope)
+	}
+
+	key, hash := auth.GenerateAPIKey()
+	entry, err := yaml.Marshal([]auth.APIKey{{
+		Name:      *name,
+		Hash:      hash,
+		Scopes:    scopes,
+		Role:      auth.Role(*role),
+		ClientIDs: clientIDs,
+	}})
+	if err != nil {
+		fatal("failed to encode key entry", "error", err)
+	}
+
+	fmt.Printf("API key (store it now, it is not saved anywhere):\n\n  %s\n\nAdd to the keys list in the API keys file:\n\n%s", key, entry)
+}
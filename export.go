@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"order-service/internal/database"
+	"order-service/internal/export"
+	"order-service/internal/models"
+)
+
+// runExport выгружает заказы из БД в файл или stdout:
+//
+//	order-service export -format csv -client-id 42 -from 2025-01-01 -out orders.csv
+//
+// Параметры подключения к БД берутся из общей конфигурации, их можно задать и флагами
+func runExport(args []string, envErr error) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	formatFlag := fs.String("format", "jsonl", "output format: jsonl, csv or parquet")
+	out := fs.String("out", "-", "output file, - for stdout")
+	clientID := fs.Int64("client-id", 0, "export only orders of this client")
+	from := fs.String("from", "", "created at or after (RFC3339 or YYYY-MM-DD)")
+	to := fs.String("to", "", "created before (RFC3339 or YYYY-MM-DD)")
+	limit := fs.Int("limit", 0, "maximum number of orders, 0 for no limit")
+	cfg := loadConfig(fs, args, envErr)
+
+	format, err := export.ParseFormat(*formatFlag)
+	if err != nil {
+		fatal("invalid export format", "error", err)
+	}
+
+	filter := models.OrderFilter{ClientID: *clientID, Limit: *limit}
+	if *from != "" {
+		if filter.CreatedFrom, err = models.ParseFilterTime(*from); err != nil {
+			fatal("invalid -from", "error", err)
+		}
+	}
+	if *to != "" {
+		if filter.CreatedTo, err = models.ParseFilterTime(*to); err != nil {
+			fatal("invalid -to", "error", err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	pool, err := connectDB(ctx, cfg.Database)
+	if err != nil {
+		fatal("failed to connect to PostgreSQL", "error", err)
+	}
+	defer pool.Close()
+
+	var dst io.Writer = os.Stdout
+	if *out != "-" {
+		file, err := os.Create(*out)
+		if err != nil {
+			fatal("failed to create output file", "path", *out, "error", err)
+		}
+		defer file.Close()
+		dst = file
+	}
+
+	buf := bufio.NewWriter(dst)
+	writer, err := export.NewWriter(format, buf)
+	if err != nil {
+		fatal("failed to create export writer", "error", err)
+	}
+
+	db := database.NewPostgresBase(pool)
+	db.SetKeyring(newKeyring(cfg.PII))
+	count := 0
+	err = db.StreamOrders(ctx, filter, func(order *models.Order) error {
+		count++
+		return writer.Write(order)
+	})
+	if err != nil {
+		fatal("export failed", "orders", count, "error", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		fatal("failed to finish export", "error", err)
+	}
+	if err := buf.Flush(); err != nil {
+		fatal("failed to write output", "error", err)
+	}
+
+	slog.Info("orders exported", "orders", count, "format", format)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"os/user"
+	"syscall"
+
+	"order-service/internal/database"
+	"order-service/internal/models"
+)
+
+// runGDPR выгружает или обезличивает персональные данные клиента:
+//
+//	order-service gdpr export -client-id 42 -out client-42.json
+//	order-service gdpr erase -email ivan@example.com -confirm
+//
+// Запущенные реплики сбросят кэш по уведомлениям об изменении доставки
+func runGDPR(args []string, envErr error) {
+	if len(args) == 0 || (args[0] != "export" && args[0] != "erase") {
+		fatal("usage: order-service gdpr export|erase -client-id N | -email E")
+	}
+	action := args[0]
+
+	fs := flag.NewFlagSet("gdpr "+action, flag.ExitOnError)
+	clientID := fs.Int64("client-id", 0, "client whose data is requested")
+	email := fs.String("email", "", "delivery email of the client whose data is requested")
+	out := fs.String("out", "-", "output file for export, - for stdout")
+	confirm := fs.Bool("confirm", false, "required for erase: delivery data cannot be restored")
+	cfg := loadConfig(fs, args[1:], envErr)
+
+	subject := models.DataSubject{ClientID: *clientID, Email: *email}
+	if err := subject.Validate(); err != nil {
+		fatal("invalid subject", "error", err)
+	}
+	if action == "erase" && !*confirm {
+		fatal("erase is irreversible, pass -confirm to proceed")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	pool, err := connectDB(ctx, cfg.Database)
+	if err != nil {
+		fatal("failed to connect to PostgreSQL", "error", err)
+	}
+	defer pool.Close()
+
+	db := database.NewPostgresBase(pool)
+	db.SetKeyring(newKeyring(cfg.PII))
+	// Миграция создает журнал gdpr_requests
+	if err := db.InitDB(ctx); err != nil {
+		fatal("failed to initialize database", "error", err)
+	}
+
+	actor := "cli"
+	if u, err := user.Current(); err == nil {
+		actor += ":" + u.Username
+	}
+
+	if action == "erase" {
+		result, err := db.EraseSubjectData(ctx, subject, nil, actor)
+		if err != nil {
+			fatal("erase failed", "error", err)
+		}
+		slog.Info("subject data erased", "request_id", result.RequestID, "orders", len(result.OrderIDs))
+		return
+	}
+
+	result, err := db.ExportSubjectData(ctx, subject, nil, actor)
+	if err != nil {
+		fatal("export failed", "error", err)
+	}
+
+	var dst io.Writer = os.Stdout
+	if *out != "-" {
+		file, err := os.Create(*out)
+		if err != nil {
+			fatal("failed to create output file", "path", *out, "error", err)
+		}
+		defer file.Close()
+		dst = file
+	}
+
+	enc := json.NewEncoder(dst)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(result); err != nil {
+		fatal("failed to write output", "error", err)
+	}
+	slog.Info("subject data exported", "request_id", result.RequestID, "orders", len(result.Orders), "out", *out)
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+func (h *Handler) CacheStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.service.CacheStats())
+}
+
+func (h *Handler) EvictCachedOrder(w http.ResponseWriter, r *http.Request) {
+	orderID := r.PathValue("order_id")
+	if !h.service.EvictCached(orderID) {
+		writeProblem(w, r, http.StatusNotFound, "order not cached")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) ClearCache(w http.ResponseWriter, r *http.Request) {
+	h.service.ClearCache(r.Context())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReloadCache запускает фоновую перезагрузку кэша из БД, прогресс доступен в /api/v1/cache/warmup
+func (h *Handler) ReloadCache(w http.ResponseWriter, r *http.Request) {
+	// Перезагрузка переживает запрос, который ее запустил
+	if err := h.service.ReloadCache(context.WithoutCancel(r.Context())); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(h.service.WarmupProgress())
+}
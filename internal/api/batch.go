@@ -0,0 +1,157 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"order-service/internal/errs"
+	"order-service/internal/models"
+	"order-service/internal/service"
+)
+
+// Максимальное число заказов в одном пакетном запросе
+const maxBatchSize = 100
+
+// Время на сохранение пакета. Оно больше WriteTimeout сервера по умолчанию,
+// поэтому срок записи ответа продлевается
+const batchTimeout = 30 * time.Second
+
+type batchItemResult struct {
+	Index   int    `json:"index"`
+	OrderID string `json:"order_id"`
+	Status  int    `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+type batchCreateResponse struct {
+	Mode    string            `json:"mode"`
+	Results []batchItemResult `json:"results"`
+}
+
+func (h *Handler) CreateOrdersBatch(w http.ResponseWriter, r *http.Request) {
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = "atomic"
+	}
+	if mode != "atomic" && mode != "best_effort" {
+		writeProblem(w, r, http.StatusBadRequest, "mode must be atomic or best_effort")
+		return
+	}
+	atomic := mode == "atomic"
+
+	var orders []models.Order
+	if !decodeJSON(w, r, &orders) {
+		return
+	}
+
+	if len(orders) == 0 {
+		writeProblem(w, r, http.StatusBadRequest, "at least one order is required")
+		return
+	}
+	if len(orders) > maxBatchSize {
+		writeProblem(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("batch size exceeds limit of %d", maxBatchSize))
+		return
+	}
+
+	results := make([]batchItemResult, len(orders))
+	valid := make([]*models.Order, 0, len(orders))
+	validIdx := make([]int, 0, len(orders))
+	for i := range orders {
+		results[i] = batchItemResult{Index: i, OrderID: orders[i].OrderID}
+		if orders[i].OrderID == "" {
+			results[i].Status = http.StatusBadRequest
+			results[i].Error = "order_id is required"
+			continue
+		}
+		valid = append(valid, &orders[i])
+		validIdx = append(validIdx, i)
+	}
+
+	// В атомарном режиме невалидный заказ отменяет весь пакет еще до обращения к БД
+	if atomic && len(valid) != len(orders) {
+		for _, i := range validIdx {
+			results[i].Status = http.StatusFailedDependency
+			results[i].Error = service.ErrBatchAborted.Error()
+		}
+		writeMultiStatus(w, batchCreateResponse{Mode: mode, Results: results})
+		return
+	}
+
+	h.extendWriteDeadline(w, r, batchTimeout)
+	ctx, cancel := context.WithTimeout(r.Context(), batchTimeout)
+	defer cancel()
+
+	saveErrs := h.service.SaveOrders(ctx, valid, atomic)
+	for j, err := range saveErrs {
+		i := validIdx[j]
+		switch {
+		case err == nil:
+			results[i].Status = http.StatusCreated
+		case errors.Is(err, service.ErrBatchAborted):
+			results[i].Status = http.StatusFailedDependency
+			results[i].Error = errs.Message(err)
+		default:
+			results[i].Status = errorStatus(err)
+			results[i].Error = errs.Message(err)
+			if results[i].Status >= http.StatusInternalServerError {
+				slog.ErrorContext(ctx, "failed to save order in batch", "order_id", results[i].OrderID, "error", err)
+			}
+		}
+	}
+
+	writeMultiStatus(w, batchCreateResponse{Mode: mode, Results: results})
+}
+
+func writeMultiStatus(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusMultiStatus)
+	json.NewEncoder(w).Encode(body)
+}
+
+type batchGetRequest struct {
+	OrderIDs []string `json:"order_ids"`
+}
+
+type batchGetResponse struct {
+	Orders  []*models.Order `json:"orders"`
+	Missing []string        `json:"missing"`
+}
+
+func (h *Handler) GetOrdersBatch(w http.ResponseWriter, r *http.Request) {
+	var req batchGetRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if len(req.OrderIDs) == 0 {
+		writeProblem(w, r, http.StatusBadRequest, "order_ids is required")
+		return
+	}
+	if len(req.OrderIDs) > maxBatchSize {
+		writeProblem(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("batch size exceeds limit of %d", maxBatchSize))
+		return
+	}
+	for _, id := range req.OrderIDs {
+		if id == "" {
+			writeProblem(w, r, http.StatusBadRequest, "order_ids must not contain empty values")
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	orders, missing, err := h.service.GetOrders(ctx, req.OrderIDs)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(batchGetResponse{Orders: orders, Missing: missing})
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"order-service/internal/errs"
+	"order-service/internal/export"
+	"order-service/internal/models"
+)
+
+func parseOrderFilter(query url.Values) (models.OrderFilter, error) {
+	var filter models.OrderFilter
+
+	if v := query.Get("client_id"); v != "" {
+		clientID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid client_id %q", v)
+		}
+		filter.ClientID = clientID
+	}
+
+	if v := query.Get("from"); v != "" {
+		from, err := models.ParseFilterTime(v)
+		if err != nil {
+			return filter, err
+		}
+		filter.CreatedFrom = from
+	}
+
+	if v := query.Get("to"); v != "" {
+		to, err := models.ParseFilterTime(v)
+		if err != nil {
+			return filter, err
+		}
+		filter.CreatedTo = to
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			return filter, fmt.Errorf("invalid limit %q", v)
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+// Число заказов, после которого выгрузка сбрасывает ответ клиенту
+const exportBatchSize = 1000
+
+func (h *Handler) ExportOrders(w http.ResponseWriter, r *http.Request) {
+	format, err := export.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	filter, err := parseOrderFilter(r.URL.Query())
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.streamExport(w, r, format, func(fn func(*models.Order) error) error {
+		return h.service.ExportOrders(r.Context(), filter, fn)
+	})
+}
+
+// streamExport пишет в ответ заказы, которые stream передает в fn
+func (h *Handler) streamExport(w http.ResponseWriter, r *http.Request, format export.Format, stream func(fn func(*models.Order) error) error) {
+	// Выгрузка может идти дольше WriteTimeout сервера, поэтому срок записи
+	// продлевается после каждой отправленной порции. Клиент, который перестал
+	// читать, все равно упрется в таймаут и освободит соединение с БД
+	rc := http.NewResponseController(w)
+	extendDeadline := func() {
+		if h.limits.WriteTimeout <= 0 {
+			return
+		}
+		if err := rc.SetWriteDeadline(time.Now().Add(h.limits.WriteTimeout)); err != nil {
+			slog.WarnContext(r.Context(), "failed to extend write deadline for export", "error", err)
+		}
+	}
+	extendDeadline()
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="orders.%s"`, format))
+
+	writer, err := export.NewWriter(format, w)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	count := 0
+	err = stream(func(order *models.Order) error {
+		count++
+		if err := writer.Write(order); err != nil {
+			return err
+		}
+		if count%exportBatchSize == 0 {
+			if err := rc.Flush(); err != nil {
+				return err
+			}
+			extendDeadline()
+		}
+		return nil
+	})
+	if err == nil {
+		err = writer.Close()
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "export failed", "orders", count, "error", err)
+		if count == 0 {
+			w.Header().Del("Content-Disposition")
+			writeProblem(w, r, errorStatus(err), errs.Message(err))
+			return
+		}
+		// Данные уже ушли клиенту. Обычное завершение закрыло бы ответ как
+		// успешный, и клиент получил бы обрезанный файл. Обрываем соединение,
+		// чтобы передача явно завершилась ошибкой
+		panic(http.ErrAbortHandler)
+	}
+
+	slog.InfoContext(r.Context(), "orders exported", "orders", count, "format", format)
+}
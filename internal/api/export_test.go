@@ -0,0 +1,82 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"order-service/internal/errs"
+	"order-service/internal/export"
+	"order-service/internal/models"
+)
+
+// exportServer отдает выгрузку из n заказов, после которых stream возвращает failAfter
+func exportServer(t *testing.T, n int, failAfter error) *httptest.Server {
+	t.Helper()
+	h := NewHandler(nil, nil, "test")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.streamExport(w, r, export.FormatJSONL, func(fn func(*models.Order) error) error {
+			for i := range n {
+				if err := fn(&models.Order{OrderID: fmt.Sprintf("o%d", i)}); err != nil {
+					return err
+				}
+			}
+			return failAfter
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestExportAbortsOnMidStreamError(t *testing.T) {
+	dbErr := errs.Wrap(errs.Unavailable, "database is unavailable", errors.New("connection reset"))
+	srv := exportServer(t, exportBatchSize+1, dbErr)
+
+	// Первая порция уже отправлена, поэтому заголовки ответа дойдут
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.ReadAll(resp.Body); err == nil {
+		t.Fatal("truncated export was delivered as a complete response")
+	}
+}
+
+func TestExportErrorBeforeFirstRow(t *testing.T) {
+	dbErr := errs.Wrap(errs.Unavailable, "database is unavailable", errors.New("connection refused"))
+	srv := exportServer(t, 0, dbErr)
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", resp.StatusCode)
+	}
+	if resp.Header.Get("Content-Disposition") != "" {
+		t.Fatal("error response is offered as a file download")
+	}
+}
+
+func TestExportCompletes(t *testing.T) {
+	srv := exportServer(t, 3, nil)
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("complete export failed: %v", err)
+	}
+	if lines := bytes.Count(body, []byte("\n")); lines != 3 {
+		t.Fatalf("exported %d orders, want 3", lines)
+	}
+}
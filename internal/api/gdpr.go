@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"order-service/internal/models"
+)
+
+// Клиент передается в теле, а не в URL, чтобы email не попадал в access-логи
+func decodeSubject(w http.ResponseWriter, r *http.Request) (models.DataSubject, bool) {
+	var subject models.DataSubject
+	ok := decodeJSON(w, r, &subject)
+	return subject, ok
+}
+
+// ExportSubjectData отдает JSON-пакет со всеми заказами клиента
+func (h *Handler) ExportSubjectData(w http.ResponseWriter, r *http.Request) {
+	subject, ok := decodeSubject(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	result, err := h.service.ExportSubjectData(ctx, subject)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="subject-data-%d.json"`, result.RequestID))
+	json.NewEncoder(w).Encode(result)
+}
+
+// EraseSubjectData обезличивает данные доставки клиента
+func (h *Handler) EraseSubjectData(w http.ResponseWriter, r *http.Request) {
+	subject, ok := decodeSubject(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	result, err := h.service.EraseSubjectData(ctx, subject)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
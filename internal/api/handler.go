@@ -3,39 +3,62 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"sync/atomic"
 	"time"
 
+	"order-service/internal/auth"
 	"order-service/internal/models"
+	"order-service/internal/ratelimit"
 	"order-service/internal/service"
 )
 
 type Handler struct {
 	service *service.OrderService
+	auth    *auth.Authenticator
+	limits  Limits
+	// Неудачные попытки аутентификации по IP клиента
+	authFailures *ratelimit.Limiter
+
+	version      string
+	startedAt    time.Time
+	shuttingDown atomic.Bool
 }
 
-func NewHandler(service *service.OrderService) *Handler {
-	return &Handler{service: service}
+func NewHandler(service *service.OrderService, authenticator *auth.Authenticator, version string) *Handler {
+	return &Handler{service: service, auth: authenticator, version: version, startedAt: time.Now()}
 }
 
 func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) {
-	orderID := r.URL.Query().Get("order_id")
+	// В устаревшем маршруте /api/order ID передается в строке запроса
+	orderID := r.PathValue("order_id")
 	if orderID == "" {
-		http.Error(w, "order_id is required", http.StatusBadRequest)
+		orderID = r.URL.Query().Get("order_id")
+	}
+	if orderID == "" {
+		writeProblem(w, r, http.StatusBadRequest, "order_id is required")
 		return
 	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	order, err := h.service.GetOrder(ctx, orderID)
+	order, cached, err := h.service.LookupOrder(ctx, orderID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
+	if cached {
+		w.Header().Set("X-Cache", "HIT")
+	} else {
+		w.Header().Set("X-Cache", "MISS")
+	}
+
 	if order == nil {
-		http.Error(w, "order not found", http.StatusNotFound)
+		writeProblem(w, r, http.StatusNotFound, "order not found")
 		return
 	}
 
@@ -44,19 +67,13 @@ func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	var order models.Order
-	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &order) {
 		return
 	}
 
 	if order.OrderID == "" {
-		http.Error(w, "order_id is required", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, "order_id is required")
 		return
 	}
 
@@ -64,7 +81,7 @@ func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	if err := h.service.SaveOrder(ctx, &order); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
@@ -72,6 +89,37 @@ func (h *Handler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "created", "order_id": order.OrderID})
 }
 
+// OrderHistory отдает журнал изменений заказа. ?limit ограничивает число записей
+func (h *Handler) OrderHistory(w http.ResponseWriter, r *http.Request) {
+	orderID := r.PathValue("order_id")
+
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeProblem(w, r, http.StatusBadRequest, fmt.Sprintf("invalid limit %q", v))
+			return
+		}
+		limit = n
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	entries, err := h.service.OrderHistory(ctx, orderID, limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if len(entries) == 0 {
+		writeProblem(w, r, http.StatusNotFound, "order history not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
 func (h *Handler) ServeStatic(w http.ResponseWriter, r *http.Request) {
 	http.ServeFile(w, r, "static/index.html")
 }
@@ -85,3 +133,14 @@ func (h *Handler) ServeCSS(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/css")
 	http.ServeFile(w, r, "static/styles.css")
 }
+
+// CacheWarmup показывает прогресс прогрева кэша и отвечает 503, пока он не завершен
+func (h *Handler) CacheWarmup(w http.ResponseWriter, r *http.Request) {
+	progress := h.service.WarmupProgress()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !progress.Done {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(progress)
+}
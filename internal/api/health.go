@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"runtime"
+	"time"
+
+	"order-service/internal/cache"
+	"order-service/internal/database"
+	"order-service/internal/service"
+)
+
+var (
+	errShuttingDown     = errors.New("server is shutting down")
+	errWarmupInProgress = errors.New("cache warm-up in progress")
+)
+
+// SetShuttingDown переводит /readyz в 503, чтобы балансировщик перестал слать трафик до остановки сервера
+func (h *Handler) SetShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+type readinessCheck struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type readinessResponse struct {
+	Ready  bool                      `json:"ready"`
+	Checks map[string]readinessCheck `json:"checks"`
+}
+
+func (h *Handler) readiness(ctx context.Context) readinessResponse {
+	resp := readinessResponse{Ready: true, Checks: make(map[string]readinessCheck)}
+	check := func(name string, err error) {
+		if err != nil {
+			resp.Ready = false
+			resp.Checks[name] = readinessCheck{Error: err.Error()}
+			return
+		}
+		resp.Checks[name] = readinessCheck{OK: true}
+	}
+
+	if h.shuttingDown.Load() {
+		check("shutdown", errShuttingDown)
+	} else {
+		check("shutdown", nil)
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	check("database", h.service.CheckDB(pingCtx))
+
+	if h.service.WarmupDone() {
+		check("cache_warmup", nil)
+	} else {
+		check("cache_warmup", errWarmupInProgress)
+	}
+
+	return resp
+}
+
+// Healthz - процесс жив и обслуживает запросы
+func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// Readyz - сервис готов принимать трафик: БД доступна, схема применена, первый прогрев кэша завершен и нет остановки
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	resp := h.readiness(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	if !resp.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+type statusResponse struct {
+	Version   string                 `json:"version"`
+	GoVersion string                 `json:"go_version"`
+	StartedAt time.Time              `json:"started_at"`
+	Uptime    string                 `json:"uptime"`
+	Readiness readinessResponse      `json:"readiness"`
+	Pool      database.PoolStats     `json:"db_pool"`
+	Cache     cache.Stats            `json:"cache"`
+	Warmup    service.WarmupProgress `json:"cache_warmup"`
+}
+
+func (h *Handler) Status(w http.ResponseWriter, r *http.Request) {
+	resp := statusResponse{
+		Version:   h.version,
+		GoVersion: runtime.Version(),
+		StartedAt: h.startedAt,
+		Uptime:    time.Since(h.startedAt).Round(time.Second).String(),
+		Readiness: h.readiness(r.Context()),
+		Pool:      h.service.PoolStats(),
+		Cache:     h.service.CacheStats(),
+		Warmup:    h.service.WarmupProgress(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+// TestInternalsRequireAdmin проверяет, что статус и метрики, раскрывающие
+// внутреннее состояние, закрыты правом admin, а пробы оркестратора открыты
+func TestInternalsRequireAdmin(t *testing.T) {
+	api := newTestAPI(t, Limits{}, "admin", "reader")
+
+	for _, target := range []string{"/status", "/metrics"} {
+		if rec := api.request(target, "", "10.0.0.1"); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s with wrong key: status %d, want 401", target, rec.Code)
+		}
+		if rec := api.request(target, "reader", "10.0.0.1"); rec.Code != http.StatusForbidden {
+			t.Errorf("%s with read scope: status %d, want 403", target, rec.Code)
+		}
+	}
+	if rec := api.request("/metrics", "admin", "10.0.0.1"); rec.Code != http.StatusOK {
+		t.Errorf("/metrics with admin scope: status %d, want 200", rec.Code)
+	}
+	if rec := api.request("/healthz", "", "10.0.0.1"); rec.Code != http.StatusOK {
+		t.Errorf("/healthz without credentials: status %d, want 200", rec.Code)
+	}
+}
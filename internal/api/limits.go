@@ -0,0 +1,201 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"order-service/internal/auth"
+	"order-service/internal/ratelimit"
+)
+
+// Limits - ограничения на запросы к API. Задаются через SetLimits до SetupRoutes
+type Limits struct {
+	// Максимальный размер тела запроса
+	MaxBodyBytes int64
+	// Лимит частоты по умолчанию, nil - частота не ограничивается
+	DefaultRate *ratelimit.Limit
+	// Лимит неудачных попыток аутентификации с одного IP, nil - не ограничивается
+	AuthFailureRate *ratelimit.Limit
+	// Лимиты отдельных маршрутов по шаблону ServeMux ("POST /api/v1/orders")
+	RouteRates map[string]ratelimit.Limit
+	// Брать IP клиента из X-Forwarded-For
+	TrustProxy bool
+	// Число доверенных прокси, дописывающих адрес в конец X-Forwarded-For
+	TrustedProxies int
+	// WriteTimeout сервера. Потоковая выгрузка продлевает его после каждой порции
+	WriteTimeout time.Duration
+}
+
+func (h *Handler) SetLimits(limits Limits) {
+	h.limits = limits
+	h.authFailures = nil
+	if limits.AuthFailureRate != nil {
+		h.authFailures = ratelimit.New(*limits.AuthFailureRate)
+	}
+}
+
+// extendWriteDeadline дает обработчику, который работает до timeout, время
+// на ответ: срок записи отсчитывается от текущего момента и включает
+// WriteTimeout на саму отправку
+func (h *Handler) extendWriteDeadline(w http.ResponseWriter, r *http.Request, timeout time.Duration) {
+	if h.limits.WriteTimeout <= 0 {
+		return
+	}
+	err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout + h.limits.WriteTimeout))
+	if err != nil {
+		slog.WarnContext(r.Context(), "failed to extend write deadline", "path", r.URL.Path, "error", err)
+	}
+}
+
+// rateLimiter возвращает лимитер маршрута route, nil - частота не ограничивается
+func (h *Handler) rateLimiter(route string) *ratelimit.Limiter {
+	limit, ok := h.limits.RouteRates[route]
+	if !ok {
+		if h.limits.DefaultRate == nil {
+			return nil
+		}
+		limit = *h.limits.DefaultRate
+	}
+	return ratelimit.New(limit)
+}
+
+// rateLimit ограничивает частоту запросов к маршруту route отдельно для
+// каждого клиента. Должен стоять после requireScope, чтобы клиент был известен
+func (h *Handler) rateLimit(route string, limiter *ratelimit.Limiter, next http.Handler) http.Handler {
+	if limiter == nil {
+		return next
+	}
+	limit := limiter.Limit()
+	policy := fmt.Sprintf("%d;w=%d;burst=%d", limit.Quota(), int(limit.Window.Seconds()), limit.Burst)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := h.clientKey(r)
+		res := limiter.Allow(client)
+		setRateLimitHeaders(w, policy, limit, res)
+
+		if !res.Allowed {
+			slog.InfoContext(r.Context(), "rate limit exceeded", "route", route, "client", client)
+			writeProblem(w, r, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func setRateLimitHeaders(w http.ResponseWriter, policy string, limit ratelimit.Limit, res ratelimit.Result) {
+	w.Header().Set("RateLimit-Policy", policy)
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+	if !res.Allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+	}
+}
+
+// clientKey - ключ клиента для лимита: API-ключ или sub из JWT, без
+// аутентификации - IP. Клиенты за одним NAT с разными ключами не делят лимит
+func (h *Handler) clientKey(r *http.Request) string {
+	if auth.FromContext(r.Context()) != nil {
+		return auth.Actor(r.Context())
+	}
+	return h.ipKey(r)
+}
+
+func (h *Handler) ipKey(r *http.Request) string {
+	return "ip:" + h.clientIP(r)
+}
+
+// authFailed учитывает неудачную попытку аутентификации с IP клиента
+func (h *Handler) authFailed(r *http.Request) {
+	if h.authFailures != nil {
+		h.authFailures.Allow(h.ipKey(r))
+	}
+}
+
+// authBlocked отвечает 429, если с IP клиента исчерпан лимит неудачных попыток
+// аутентификации. Проверяется до разбора учетных данных, в том числе подписи JWT
+func (h *Handler) authBlocked(w http.ResponseWriter, r *http.Request) bool {
+	if h.authFailures == nil {
+		return false
+	}
+	res := h.authFailures.Check(h.ipKey(r))
+	if res.Allowed {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+	slog.InfoContext(r.Context(), "too many failed authentication attempts", "client", h.ipKey(r))
+	writeProblem(w, r, http.StatusTooManyRequests, "too many failed authentication attempts")
+	return true
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// clientIP возвращает адрес клиента. Начало X-Forwarded-For клиент может
+// подставить сам, поэтому адрес берется с конца списка: последний доверенный
+// прокси записывает туда адрес, с которого к нему пришел запрос
+func (h *Handler) clientIP(r *http.Request) string {
+	if h.limits.TrustProxy {
+		var hops []string
+		for _, value := range r.Header.Values("X-Forwarded-For") {
+			hops = append(hops, strings.Split(value, ",")...)
+		}
+		// Если адресов меньше, чем прокси, запрос пришел в обход цепочки
+		if n := max(h.limits.TrustedProxies, 1); len(hops) >= n {
+			if ip := strings.TrimSpace(hops[len(hops)-n]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// limitBody ограничивает размер тела запроса
+func (h *Handler) limitBody(next http.Handler) http.Handler {
+	if h.limits.MaxBodyBytes <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, h.limits.MaxBodyBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// decodeJSON строго разбирает тело запроса в v: неизвестные поля и данные после
+// JSON-значения считаются ошибкой. При ошибке отвечает клиенту и возвращает false
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(v)
+	if err == nil {
+		if _, extra := dec.Token(); extra != io.EOF {
+			err = errors.New("request body must contain a single JSON value")
+		}
+	}
+	if err == nil {
+		return true
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeProblem(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds limit of %d bytes", tooLarge.Limit))
+		return false
+	}
+	writeProblem(w, r, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+	return false
+}
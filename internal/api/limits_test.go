@@ -0,0 +1,210 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"order-service/internal/auth"
+	"order-service/internal/cache"
+	"order-service/internal/models"
+	"order-service/internal/ratelimit"
+	"order-service/internal/service"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		trust     bool
+		proxies   int
+		forwarded []string
+		want      string
+	}{
+		{name: "proxy not trusted", forwarded: []string{"1.1.1.1"}, want: "10.0.0.1"},
+		{name: "rightmost entry", trust: true, proxies: 1, forwarded: []string{"6.6.6.6, 1.1.1.1"}, want: "1.1.1.1"},
+		{name: "spoofed entries are skipped", trust: true, proxies: 2, forwarded: []string{"6.6.6.6, 1.1.1.1, 2.2.2.2"}, want: "1.1.1.1"},
+		{name: "several headers", trust: true, proxies: 1, forwarded: []string{"6.6.6.6", "1.1.1.1"}, want: "1.1.1.1"},
+		{name: "fewer entries than proxies", trust: true, proxies: 2, forwarded: []string{"1.1.1.1"}, want: "10.0.0.1"},
+		{name: "no header", trust: true, proxies: 1, want: "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{limits: Limits{TrustProxy: tt.trust, TrustedProxies: tt.proxies}}
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = "10.0.0.1:5000"
+			for _, v := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := h.clientIP(r); got != tt.want {
+				t.Fatalf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+type testAPI struct {
+	mux  *http.ServeMux
+	keys map[string]string
+}
+
+// newTestAPI поднимает маршруты с API-ключами names и лимитами limits.
+// Ключ с именем admin получает право admin, остальные - read. Заказ o1 лежит
+// в кэше, поэтому GET /api/v1/orders/o1 обходится без БД
+func newTestAPI(t *testing.T, limits Limits, names ...string) *testAPI {
+	t.Helper()
+	api := &testAPI{mux: http.NewServeMux(), keys: map[string]string{}}
+	var yaml strings.Builder
+	yaml.WriteString("keys:\n")
+	for _, name := range names {
+		key, hash := auth.GenerateAPIKey()
+		api.keys[name] = key
+		scope := auth.ScopeRead
+		if name == "admin" {
+			scope = auth.ScopeAdmin
+		}
+		fmt.Fprintf(&yaml, "  - name: %s\n    hash: %s\n    scopes: [%s]\n    role: admin\n", name, hash, scope)
+	}
+	keysFile := filepath.Join(t.TempDir(), "keys.yaml")
+	if err := os.WriteFile(keysFile, []byte(yaml.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	authenticator, err := auth.New(auth.Config{APIKeysFile: keysFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orders := cache.NewCache()
+	orders.Set(&models.Order{OrderID: "o1", ClientID: 1})
+	h := NewHandler(service.NewOrderService(nil, orders), authenticator, "test")
+	h.SetLimits(limits)
+	h.SetupRoutes(api.mux)
+	return api
+}
+
+// get запрашивает заказ с ключом name (пустое имя - неверный ключ) с адреса ip
+func (a *testAPI) get(name, ip string) *httptest.ResponseRecorder {
+	return a.request("/api/v1/orders/o1", name, ip)
+}
+
+func (a *testAPI) request(target, name, ip string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	r.RemoteAddr = ip + ":5000"
+	key := "wrong"
+	if name != "" {
+		key = a.keys[name]
+	}
+	r.Header.Set("X-API-Key", key)
+	rec := httptest.NewRecorder()
+	a.mux.ServeHTTP(rec, r)
+	return rec
+}
+
+func mustLimit(t *testing.T, value string) *ratelimit.Limit {
+	t.Helper()
+	limit, err := ratelimit.ParseLimit(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &limit
+}
+
+func TestRateLimitPerKey(t *testing.T) {
+	api := newTestAPI(t, Limits{DefaultRate: mustLimit(t, "2/h")}, "alice", "bob")
+
+	for i := range 2 {
+		if rec := api.get("alice", "10.0.0.1"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d, want 200", i+1, rec.Code)
+		}
+	}
+
+	rec := api.get("alice", "10.0.0.1")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("over limit: status %d, want 429", rec.Code)
+	}
+	// Токен появляется через 30 минут при лимите 2 в час
+	if got := rec.Header().Get("Retry-After"); got != "1800" {
+		t.Errorf("Retry-After = %q, want 1800", got)
+	}
+	if got := rec.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("RateLimit-Remaining = %q, want 0", got)
+	}
+	if got := rec.Header().Get("RateLimit-Policy"); got != "2;w=3600;burst=2" {
+		t.Errorf("RateLimit-Policy = %q", got)
+	}
+
+	// Другой ключ за тем же IP (NAT) считается отдельно
+	if rec := api.get("bob", "10.0.0.1"); rec.Code != http.StatusOK {
+		t.Errorf("other key behind the same IP: status %d, want 200", rec.Code)
+	}
+	// Тот же ключ с другого IP лимит не обходит
+	if rec := api.get("alice", "10.0.0.2"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("same key from another IP: status %d, want 429", rec.Code)
+	}
+}
+
+// TestAuthFailureLimit проверяет, что неудачные попытки аутентификации
+// ограничиваются по IP и не расходуют лимит успешных запросов
+func TestAuthFailureLimit(t *testing.T) {
+	api := newTestAPI(t, Limits{AuthFailureRate: mustLimit(t, "2/h")}, "alice")
+
+	// Успешные запросы не считаются неудачными попытками
+	for range 5 {
+		if rec := api.get("alice", "10.0.0.1"); rec.Code != http.StatusOK {
+			t.Fatalf("valid key: status %d, want 200", rec.Code)
+		}
+	}
+
+	for i := range 2 {
+		if rec := api.get("", "10.0.0.1"); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("failed attempt %d: status %d, want 401", i+1, rec.Code)
+		}
+	}
+	rec := api.get("", "10.0.0.1")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("over failure limit: status %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	// IP заблокирован до проверки учетных данных, другие IP - нет
+	if rec := api.get("alice", "10.0.0.1"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("valid key from blocked IP: status %d, want 429", rec.Code)
+	}
+	if rec := api.get("alice", "10.0.0.2"); rec.Code != http.StatusOK {
+		t.Errorf("valid key from another IP: status %d, want 200", rec.Code)
+	}
+}
+
+// TestExtendWriteDeadline проверяет, что ответ обработчика, работающего дольше
+// WriteTimeout сервера, доходит до клиента
+func TestExtendWriteDeadline(t *testing.T) {
+	const writeTimeout = 100 * time.Millisecond
+	h := &Handler{limits: Limits{WriteTimeout: writeTimeout}}
+
+	for _, extend := range []bool{false, true} {
+		srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if extend {
+				h.extendWriteDeadline(w, r, 3*writeTimeout)
+			}
+			time.Sleep(2 * writeTimeout)
+			w.Write([]byte("done"))
+		}))
+		srv.Config.WriteTimeout = writeTimeout
+		srv.Start()
+
+		resp, err := http.Get(srv.URL)
+		var body []byte
+		if err == nil {
+			body, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		srv.Close()
+
+		if got := err == nil && string(body) == "done"; got != extend {
+			t.Errorf("extend=%v: body %q, err %v", extend, body, err)
+		}
+	}
+}
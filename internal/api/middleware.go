@@ -0,0 +1,146 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"order-service/internal/auth"
+	"order-service/internal/logging"
+	"order-service/internal/metrics"
+	"order-service/internal/telemetry"
+)
+
+var tracer = telemetry.Tracer("api")
+
+// statusRecorder запоминает код ответа и размер тела. Unwrap нужен http.ResponseController
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// instrument замеряет длительность запросов к маршруту route и открывает серверный span,
+// продолжая трейс из заголовка traceparent
+func instrument(route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", rec.status))
+		if rec.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+		metrics.ObserveHTTPRequest(r.Method, route, rec.status, time.Since(start))
+	})
+}
+
+// requireScope пропускает запрос, только если клиент аутентифицирован и имеет право scope.
+// При выключенной аутентификации запросы проходят без проверки. IP, с которого
+// исчерпан лимит неудачных попыток, получает 429 без проверки учетных данных
+func (h *Handler) requireScope(scope auth.Scope, next http.Handler) http.Handler {
+	if !h.auth.Enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.authBlocked(w, r) {
+			return
+		}
+		principal, err := h.auth.Authenticate(r)
+		if err != nil {
+			h.authFailed(r)
+			slog.InfoContext(r.Context(), "authentication failed", "path", r.URL.Path, "error", err)
+			challenge := `Bearer realm="order-service"`
+			if !errors.Is(err, auth.ErrNoCredentials) {
+				challenge += `, error="invalid_token"`
+			}
+			w.Header().Set("WWW-Authenticate", challenge)
+			writeProblem(w, r, http.StatusUnauthorized, "authentication required")
+			return
+		}
+
+		if !principal.Has(scope) {
+			slog.InfoContext(r.Context(), "access denied", "path", r.URL.Path, "subject", principal.Subject, "required_scope", scope)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="order-service", error="insufficient_scope", scope="`+string(scope)+`"`)
+			writeProblem(w, r, http.StatusForbidden, "insufficient scope: "+string(scope)+" required")
+			return
+		}
+
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("enduser.id", principal.Subject))
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	})
+}
+
+// RequestLogger присваивает запросу X-Request-ID (или берет переданный клиентом),
+// кладет его в контекст и пишет access-лог
+func RequestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		requestID := r.Header.Get("X-Request-ID")
+		if !logging.ValidRequestID(requestID) {
+			requestID = logging.NewRequestID()
+		}
+		w.Header().Set("X-Request-ID", requestID)
+
+		ctx := logging.WithRequestID(r.Context(), requestID)
+		rec := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		slog.InfoContext(ctx, "http request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"latency_ms", float64(time.Since(start).Microseconds())/1000,
+			"bytes", rec.bytes,
+			"remote_addr", r.RemoteAddr,
+		)
+	})
+}
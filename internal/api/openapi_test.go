@@ -0,0 +1,168 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"order-service/internal/auth"
+)
+
+// Маршруты, которые намеренно не описаны в спецификации: метрики,
+// сама документация и статика веб-интерфейса
+var undocumentedRoutes = map[string]bool{
+	"GET /metrics":                   true,
+	"GET /openapi.json":              true,
+	"GET /docs":                      true,
+	"GET /docs/swagger-ui.css":       true,
+	"GET /docs/swagger-ui-bundle.js": true,
+	"GET /":                          true,
+	"GET /script.js":                 true,
+	"GET /styles.css":                true,
+}
+
+// recordingRouter запоминает шаблоны и регистрирует их в настоящем ServeMux
+type recordingRouter struct {
+	*http.ServeMux
+	patterns []string
+}
+
+func (r *recordingRouter) Handle(pattern string, handler http.Handler) {
+	r.patterns = append(r.patterns, pattern)
+	r.ServeMux.Handle(pattern, handler)
+}
+
+type openAPIDocument struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Required   []string                   `json:"required"`
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+type openAPIOperation struct {
+	Responses map[string]json.RawMessage `json:"responses"`
+}
+
+func loadSpec(t *testing.T) openAPIDocument {
+	t.Helper()
+	var doc openAPIDocument
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	return doc
+}
+
+func newTestRouter(t *testing.T) *recordingRouter {
+	t.Helper()
+	authenticator, err := auth.New(auth.Config{AllowOpen: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := &recordingRouter{ServeMux: http.NewServeMux()}
+	NewHandler(nil, authenticator, "test").SetupRoutes(router)
+	return router
+}
+
+// TestRoutesMatchOpenAPISpec сверяет зарегистрированные маршруты с paths
+// спецификации в обе стороны, чтобы описание не расходилось с v1Routes
+func TestRoutesMatchOpenAPISpec(t *testing.T) {
+	doc := loadSpec(t)
+	router := newTestRouter(t)
+
+	registered := map[string]bool{}
+	for _, pattern := range router.patterns {
+		registered[pattern] = true
+		if undocumentedRoutes[pattern] {
+			continue
+		}
+		method, path, ok := strings.Cut(pattern, " ")
+		if !ok {
+			t.Errorf("route %q has no method", pattern)
+			continue
+		}
+		if _, ok := doc.Paths[path][strings.ToLower(method)]; !ok {
+			t.Errorf("route %q is not described in openapi.json", pattern)
+		}
+	}
+
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			if method == "parameters" {
+				continue
+			}
+			pattern := strings.ToUpper(method) + " " + path
+			if !registered[pattern] {
+				t.Errorf("openapi.json describes %q, but no such route is registered", pattern)
+			}
+		}
+	}
+}
+
+// TestErrorResponsesMatchSpec проверяет, что ответ об ошибке задокументирован
+// для операции и по полям совпадает со схемой Problem
+func TestErrorResponsesMatchSpec(t *testing.T) {
+	doc := loadSpec(t)
+	router := newTestRouter(t)
+	problemSchema := doc.Components.Schemas["Problem"]
+
+	tests := []struct {
+		target string
+		path   string
+		status int
+	}{
+		{"/api/v1/orders/abc/history?limit=x", "/api/v1/orders/{order_id}/history", http.StatusBadRequest},
+		{"/api/order", "/api/order", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			var op openAPIOperation
+			if err := json.Unmarshal(doc.Paths[tt.path]["get"], &op); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := op.Responses[strconv.Itoa(rec.Code)]; !ok {
+				t.Errorf("status %d is not documented for GET %s", rec.Code, tt.path)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+
+			var body map[string]any
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("body is not JSON: %v", err)
+			}
+			for key := range body {
+				if _, ok := problemSchema.Properties[key]; !ok {
+					t.Errorf("field %q is not in the Problem schema", key)
+				}
+			}
+			for _, key := range problemSchema.Required {
+				if _, ok := body[key]; !ok {
+					t.Errorf("required field %q is missing", key)
+				}
+			}
+		})
+	}
+}
+
+func TestSwaggerAssetsEmbedded(t *testing.T) {
+	router := newTestRouter(t)
+	for _, target := range []string{"/docs", "/docs/swagger-ui.css", "/docs/swagger-ui-bundle.js"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK || rec.Body.Len() == 0 {
+			t.Errorf("GET %s: status %d, %d bytes", target, rec.Code, rec.Body.Len())
+		}
+	}
+}
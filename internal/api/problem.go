@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"order-service/internal/errs"
+	"order-service/internal/logging"
+)
+
+// problem - тело ответа об ошибке по RFC 7807
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Нестандартный статус nginx для запросов, отмененных клиентом. Отделяет их
+// от ошибок сервера в логах и метриках
+const statusClientClosedRequest = 499
+
+// writeProblem отвечает application/problem+json. Тип ошибки определяется
+// статусом, поэтому type всегда about:blank
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	title := http.StatusText(status)
+	if status == statusClientClosedRequest {
+		title = "Client Closed Request"
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(problem{
+		Type:      "about:blank",
+		Title:     title,
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: logging.RequestID(r.Context()),
+	})
+}
+
+// writeError отвечает по категории ошибки из errs. Подробности внутренних
+// ошибок (SQL, адреса) клиенту не отдаются, только пишутся в лог
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	status := errorStatus(err)
+	if status >= http.StatusInternalServerError {
+		slog.ErrorContext(r.Context(), "request failed", "status", status, "error", err)
+	}
+	writeProblem(w, r, status, errs.Message(err))
+}
+
+func errorStatus(err error) int {
+	switch errs.KindOf(err) {
+	case errs.Validation:
+		return http.StatusBadRequest
+	case errs.NotFound:
+		return http.StatusNotFound
+	case errs.Conflict:
+		return http.StatusConflict
+	case errs.Forbidden:
+		return http.StatusForbidden
+	case errs.Unavailable:
+		return http.StatusServiceUnavailable
+	case errs.Canceled:
+		return statusClientClosedRequest
+	case errs.Timeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
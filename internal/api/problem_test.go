@@ -0,0 +1,42 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"order-service/internal/errs"
+)
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{fmt.Errorf("failed to get order: %w", context.Canceled), statusClientClosedRequest},
+		{fmt.Errorf("failed to get order: %w", context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{errs.New(errs.Unavailable, "database is unavailable"), http.StatusServiceUnavailable},
+		{fmt.Errorf("boom"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := errorStatus(tt.err); got != tt.status {
+			t.Errorf("errorStatus(%v) = %d, want %d", tt.err, got, tt.status)
+		}
+	}
+}
+
+func TestClientClosedRequestProblem(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeError(rec, httptest.NewRequest(http.MethodGet, "/api/v1/orders/o1", nil), context.Canceled)
+
+	var body problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != statusClientClosedRequest || body.Title == "" || body.Detail != "request canceled" {
+		t.Fatalf("unexpected response %d: %+v", rec.Code, body)
+	}
+}
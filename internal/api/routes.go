@@ -1,11 +1,145 @@
 package api
 
-import "net/http"
-
-func (h *Handler) SetupRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("GET /api/order", h.GetOrder)
-	mux.HandleFunc("POST /api/order", h.CreateOrder)
-	mux.HandleFunc("GET /", h.ServeStatic)
-	mux.HandleFunc("GET /script.js", h.ServeJS)
-	mux.HandleFunc("GET /styles.css", h.ServeCSS)
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"order-service/internal/auth"
+	"order-service/internal/metrics"
+)
+
+// route - маршрут версии API. Путь задается без префикса /api/<версия>
+type route struct {
+	method string
+	path   string
+	// Право, необходимое для вызова. Пустое - маршрут доступен без аутентификации
+	scope   auth.Scope
+	handler http.HandlerFunc
+	// Устаревший шаблон без версии, который ведет на этот же обработчик
+	legacy string
+}
+
+// Дата, с которой маршруты без версии считаются устаревшими (заголовок Deprecation, RFC 9745)
+const legacyDeprecation = "@1792368000" // 2026-10-19
+
+func (h *Handler) v1Routes() []route {
+	return []route{
+		{method: "GET", path: "/orders/{order_id}", scope: auth.ScopeRead, handler: h.GetOrder, legacy: "GET /api/order"},
+		{method: "POST", path: "/orders", scope: auth.ScopeWrite, handler: h.CreateOrder, legacy: "POST /api/order"},
+		{method: "GET", path: "/orders/{order_id}/history", scope: auth.ScopeRead, handler: h.OrderHistory, legacy: "GET /api/order/{order_id}/history"},
+		{method: "POST", path: "/orders:batch", scope: auth.ScopeWrite, handler: h.CreateOrdersBatch, legacy: "POST /api/orders:batch"},
+		{method: "POST", path: "/orders:batchGet", scope: auth.ScopeRead, handler: h.GetOrdersBatch, legacy: "POST /api/orders:batchGet"},
+		{method: "GET", path: "/orders:export", scope: auth.ScopeRead, handler: h.ExportOrders, legacy: "GET /api/orders/export"},
+		{method: "GET", path: "/cache/warmup", handler: h.CacheWarmup, legacy: "GET /api/cache/warmup"},
+
+		// Администрирование кэша
+		{method: "GET", path: "/admin/cache/stats", scope: auth.ScopeAdmin, handler: h.CacheStats, legacy: "GET /api/admin/cache/stats"},
+		{method: "DELETE", path: "/admin/cache/orders/{order_id}", scope: auth.ScopeAdmin, handler: h.EvictCachedOrder, legacy: "DELETE /api/admin/cache/orders/{order_id}"},
+		{method: "POST", path: "/admin/cache/clear", scope: auth.ScopeAdmin, handler: h.ClearCache, legacy: "POST /api/admin/cache/clear"},
+		{method: "POST", path: "/admin/cache/reload", scope: auth.ScopeAdmin, handler: h.ReloadCache, legacy: "POST /api/admin/cache/reload"},
+
+		// Запросы клиентов на выгрузку и удаление персональных данных
+		{method: "POST", path: "/admin/gdpr/export", scope: auth.ScopeAdmin, handler: h.ExportSubjectData, legacy: "POST /api/admin/gdpr/export"},
+		{method: "POST", path: "/admin/gdpr/erase", scope: auth.ScopeAdmin, handler: h.EraseSubjectData, legacy: "POST /api/admin/gdpr/erase"},
+	}
+}
+
+// Router принимает регистрацию маршрутов, обычно это *http.ServeMux
+type Router interface {
+	Handle(pattern string, handler http.Handler)
+}
+
+func (h *Handler) SetupRoutes(mux Router) {
+	handle := func(pattern string, handler http.HandlerFunc) {
+		mux.Handle(pattern, instrument(pattern, handler))
+	}
+
+	// Каждая версия API монтируется под своим префиксом. Новая версия получает
+	// свой список маршрутов и может переиспользовать обработчики предыдущей
+	registered := map[string]bool{}
+	h.mountAPI(mux, "v1", h.v1Routes(), registered)
+
+	// Пробы оркестратора открыты. Статус и метрики раскрывают версию и
+	// внутреннее состояние пула и кэша, поэтому требуют права admin
+	handle("GET /healthz", h.Healthz)
+	handle("GET /readyz", h.Readyz)
+	mux.Handle("GET /status", instrument("GET /status", h.requireScope(auth.ScopeAdmin, http.HandlerFunc(h.Status))))
+	mux.Handle("GET /metrics", h.requireScope(auth.ScopeAdmin, metrics.Handler()))
+
+	// Документация API
+	handle("GET /openapi.json", h.OpenAPISpec)
+	handle("GET /docs", h.APIDocs)
+	handle("GET /docs/swagger-ui.css", h.SwaggerAsset)
+	handle("GET /docs/swagger-ui-bundle.js", h.SwaggerAsset)
+
+	handle("GET /", h.ServeStatic)
+	handle("GET /script.js", h.ServeJS)
+	handle("GET /styles.css", h.ServeCSS)
+
+	for pattern := range h.limits.RouteRates {
+		if !registered[pattern] {
+			slog.Warn("rate limit configured for unknown route", "route", pattern)
+		}
+	}
+}
+
+// mountAPI регистрирует маршруты версии под /api/<version> и их устаревшие
+// псевдонимы. Псевдоним делит с маршрутом версии обработчик и лимит частоты
+func (h *Handler) mountAPI(mux Router, version string, routes []route, registered map[string]bool) {
+	prefix := "/api/" + version
+	for _, rt := range routes {
+		pattern := rt.method + " " + prefix + rt.path
+		registered[pattern] = true
+
+		limitRoute := pattern
+		if _, ok := h.limits.RouteRates[pattern]; !ok && rt.legacy != "" {
+			if _, ok := h.limits.RouteRates[rt.legacy]; ok {
+				limitRoute = rt.legacy
+			}
+		}
+		limiter := h.rateLimiter(limitRoute)
+
+		// Лимит маршрута считается после аутентификации по ключу клиента.
+		// Неудачные попытки аутентификации ограничивает requireScope по IP
+		handler := h.rateLimit(limitRoute, limiter, h.limitBody(rt.handler))
+		if rt.scope != "" {
+			handler = h.requireScope(rt.scope, handler)
+		}
+		mux.Handle(pattern, instrument(pattern, handler))
+
+		if rt.legacy != "" {
+			registered[rt.legacy] = true
+			mux.Handle(rt.legacy, instrument(rt.legacy, deprecated(prefix+rt.path, handler)))
+		}
+	}
+}
+
+// deprecated помечает ответ устаревшего маршрута заголовками Deprecation и
+// Link на маршрут-преемник. Параметры шаблона successor подставляются из пути
+// или строки запроса
+func deprecated(successor string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", legacyDeprecation)
+		w.Header().Set("Link", "<"+successorPath(successor, r)+`>; rel="successor-version"`)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func successorPath(template string, r *http.Request) string {
+	segments := strings.Split(template, "/")
+	for i, segment := range segments {
+		name, ok := strings.CutPrefix(segment, "{")
+		if !ok {
+			continue
+		}
+		name, rest, _ := strings.Cut(name, "}")
+		value := r.PathValue(name)
+		if value == "" {
+			value = r.URL.Query().Get(name)
+		}
+		segments[i] = url.PathEscape(value) + rest
+	}
+	return strings.Join(segments, "/")
 }
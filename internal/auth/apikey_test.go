@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	key, hash := GenerateAPIKey()
+	if !strings.HasPrefix(key, apiKeyPrefix) || hash != HashAPIKey(key) {
+		t.Fatalf("GenerateAPIKey = %q, %q", key, hash)
+	}
+	file := writeFile(t, "keys.yaml", `keys:
+  - name: billing
+    hash: `+strings.ToUpper(hash[:len(hashPrefix)])+hash[len(hashPrefix):]+`
+    scopes: [read, write]
+    role: partner
+    client_ids: [42]
+`)
+	a, err := New(Config{APIKeysFile: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		apiKey        string
+		wantErr       error
+	}{
+		{name: "X-API-Key", apiKey: key},
+		{name: "bearer", authorization: "Bearer " + key},
+		{name: "bearer takes precedence", authorization: "bearer " + key, apiKey: "wrong"},
+		{name: "unknown key", apiKey: key + "x", wantErr: ErrInvalidCredentials},
+		{name: "hash instead of key", apiKey: hash, wantErr: ErrInvalidCredentials},
+		{name: "basic scheme", authorization: "Basic " + key, wantErr: ErrInvalidCredentials},
+		{name: "JWT not configured", authorization: "Bearer a.b.c", wantErr: ErrInvalidCredentials},
+		{name: "no credentials", wantErr: ErrNoCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := a.AuthenticateHeaders(tt.authorization, tt.apiKey)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Subject != "billing" || p.Method != "api_key" || p.Role != RolePartner ||
+				!p.Has(ScopeWrite) || p.Has(ScopeAdmin) || !p.CanAccessClient(42) || p.CanAccessClient(43) {
+				t.Fatalf("principal = %+v", p)
+			}
+		})
+	}
+}
+
+func TestLoadAPIKeysRejects(t *testing.T) {
+	_, hash := GenerateAPIKey()
+	entry := func(fields string) string {
+		return "  - name: k\n    hash: " + hash + "\n" + fields
+	}
+	tests := []struct {
+		name string
+		file string
+	}{
+		{"no keys", "keys: []\n"},
+		{"no name", "keys:\n  - hash: " + hash + "\n    role: admin\n"},
+		{"bad hash prefix", "keys:\n  - name: k\n    hash: md5:abc\n    role: admin\n"},
+		{"short hash", "keys:\n  - name: k\n    hash: sha256:abcd\n    role: admin\n"},
+		{"unknown scope", "keys:\n" + entry("    scopes: [superuser]\n    role: admin\n")},
+		{"no role", "keys:\n" + entry("    scopes: [read]\n")},
+		{"partner without client", "keys:\n" + entry("    role: partner\n")},
+		{"duplicate hash", "keys:\n" + entry("    role: admin\n") + strings.Replace(entry("    role: admin\n"), "name: k", "name: k2", 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(Config{APIKeysFile: writeFile(t, "keys.yaml", tt.file)}); err == nil {
+				t.Fatal("invalid keys file accepted")
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Scope - уровень доступа. Уровни вложены: write включает read, admin включает все
+type Scope string
+
+const (
+	ScopeRead  Scope = "read"
+	ScopeWrite Scope = "write"
+	ScopeAdmin Scope = "admin"
+)
+
+var scopeLevels = map[Scope]int{
+	ScopeRead:  1,
+	ScopeWrite: 2,
+	ScopeAdmin: 3,
+}
+
+func ParseScope(value string) (Scope, error) {
+	s := Scope(value)
+	if _, ok := scopeLevels[s]; !ok {
+		return "", fmt.Errorf("unknown scope %q", value)
+	}
+	return s, nil
+}
+
+// Role определяет, заказы каких клиентов доступны. Какие операции разрешены, задают Scopes
+type Role string
+
+const (
+	// Заказы всех клиентов
+	RoleAdmin Role = "admin"
+	// Внутренняя интеграция, заказы всех клиентов
+	RoleService Role = "service"
+	// Сотрудник поддержки, заказы закрепленных за ним клиентов
+	RoleSupport Role = "support"
+	// Партнерская интеграция, заказы только собственного client_id
+	RolePartner Role = "partner"
+)
+
+func ParseRole(value string) (Role, error) {
+	switch r := Role(value); r {
+	case RoleAdmin, RoleService, RoleSupport, RolePartner:
+		return r, nil
+	}
+	return "", fmt.Errorf("unknown role %q", value)
+}
+
+// CheckTenancy проверяет, что список клиентов соответствует роли
+func CheckTenancy(role Role, clientIDs []int64) error {
+	switch role {
+	case RoleAdmin, RoleService:
+		if len(clientIDs) > 0 {
+			return fmt.Errorf("role %s has access to all clients and takes no client_ids", role)
+		}
+	case RoleSupport:
+		if len(clientIDs) == 0 {
+			return fmt.Errorf("role %s requires at least one client_id", role)
+		}
+	case RolePartner:
+		if len(clientIDs) != 1 {
+			return fmt.Errorf("role %s requires exactly one client_id", role)
+		}
+	default:
+		return fmt.Errorf("unknown role %q", role)
+	}
+	return nil
+}
+
+// Principal - аутентифицированный клиент API
+type Principal struct {
+	// Имя API-ключа или sub из JWT
+	Subject string
+	// api_key или jwt
+	Method string
+	Scopes []Scope
+
+	Role Role
+	// Доступные клиенты для ролей support и partner
+	ClientIDs []int64
+}
+
+// Has сообщает, дает ли набор прав principal доступ уровня scope
+func (p *Principal) Has(scope Scope) bool {
+	return slices.ContainsFunc(p.Scopes, func(s Scope) bool {
+		return scopeLevels[s] >= scopeLevels[scope]
+	})
+}
+
+// AllowedClients возвращает клиентов, которыми ограничен доступ, и false,
+// если ограничений нет. nil principal (внутренний вызов или выключенная
+// аутентификация) не ограничен
+func (p *Principal) AllowedClients() ([]int64, bool) {
+	if p == nil || p.Role == RoleAdmin || p.Role == RoleService {
+		return nil, false
+	}
+	return p.ClientIDs, true
+}
+
+func (p *Principal) CanAccessClient(clientID int64) bool {
+	ids, restricted := p.AllowedClients()
+	return !restricted || slices.Contains(ids, clientID)
+}
+
+// Actor возвращает автора действия для журналов: "<метод>:<субъект>" или
+// "anonymous", если principal в контексте нет
+func Actor(ctx context.Context) string {
+	if p := FromContext(ctx); p != nil {
+		return p.Method + ":" + p.Subject
+	}
+	return "anonymous"
+}
+
+type ctxKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// FromContext возвращает principal запроса или nil, если аутентификация отключена
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(ctxKey{}).(*Principal)
+	return p
+}
+
+var (
+	ErrNoCredentials      = errors.New("missing credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// Без учетных данных открыты в том числе администрирование, GDPR и gRPC
+	ErrNotConfigured = errors.New("authentication is not configured: set auth.api_keys_file or auth.jwks_file, or AUTH_DISABLED=true to run an open API")
+)
+
+type Config struct {
+	// YAML-файл с хэшами API-ключей, см. LoadAPIKeys
+	APIKeysFile string
+	// Локальный JWKS с ключами проверки подписи JWT
+	JWKSFile string
+	// Ожидаемые iss и aud токена, пустое значение не проверяется
+	JWTIssuer   string
+	JWTAudience string
+	// Разрешает работу без ключей и JWKS. Иначе New возвращает ErrNotConfigured
+	AllowOpen bool
+}
+
+// Authenticator проверяет API-ключи и JWT. Без ключей и JWKS (только при
+// Config.AllowOpen) он выключен, и все запросы пропускаются без проверки
+type Authenticator struct {
+	keys *apiKeys
+	jwt  *jwtVerifier
+}
+
+func New(cfg Config) (*Authenticator, error) {
+	a := &Authenticator{}
+	if cfg.APIKeysFile != "" {
+		keys, err := loadAPIKeys(cfg.APIKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		a.keys = keys
+	}
+	if cfg.JWKSFile != "" {
+		verifier, err := newJWTVerifier(cfg.JWKSFile, cfg.JWTIssuer, cfg.JWTAudience)
+		if err != nil {
+			return nil, err
+		}
+		a.jwt = verifier
+	}
+	if !a.Enabled() && !cfg.AllowOpen {
+		return nil, ErrNotConfigured
+	}
+	return a, nil
+}
+
+func (a *Authenticator) Enabled() bool {
+	return a.keys != nil || a.jwt != nil
+}
+
+// Authenticate извлекает учетные данные из Authorization: Bearer или X-API-Key
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	return a.AuthenticateHeaders(r.Header.Get("Authorization"), r.Header.Get("X-API-Key"))
+}
+
+// AuthenticateHeaders проверяет значения заголовков Authorization и X-API-Key,
+// полученные по HTTP или из метаданных gRPC. Bearer-значение из трех частей
+// через точку считается JWT, остальное - API-ключом
+func (a *Authenticator) AuthenticateHeaders(authorization, apiKey string) (*Principal, error) {
+	credential := apiKey
+	if header := authorization; header != "" {
+		scheme, value, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return nil, fmt.Errorf("%w: unsupported authorization scheme", ErrInvalidCredentials)
+		}
+		credential = strings.TrimSpace(value)
+	}
+	if credential == "" {
+		return nil, ErrNoCredentials
+	}
+
+	if strings.Count(credential, ".") == 2 {
+		if a.jwt == nil {
+			return nil, fmt.Errorf("%w: JWT authentication is not configured", ErrInvalidCredentials)
+		}
+		return a.jwt.verify(credential)
+	}
+	if a.keys == nil {
+		return nil, fmt.Errorf("%w: API key authentication is not configured", ErrInvalidCredentials)
+	}
+	return a.keys.verify(credential)
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewRequiresCredentials(t *testing.T) {
+	if _, err := New(Config{}); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("New without credentials: err = %v, want ErrNotConfigured", err)
+	}
+
+	a, err := New(Config{AllowOpen: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Enabled() {
+		t.Fatal("authenticator without credentials is enabled")
+	}
+
+	_, hash := GenerateAPIKey()
+	keys := writeFile(t, "keys.yaml", "keys:\n  - name: ci\n    hash: "+hash+"\n    scopes: [read]\n    role: service\n")
+	a, err = New(Config{APIKeysFile: keys})
+	if err != nil || !a.Enabled() {
+		t.Fatalf("New with API keys: enabled %v, err %v", a != nil && a.Enabled(), err)
+	}
+}
+
+func TestPrincipalHas(t *testing.T) {
+	tests := []struct {
+		scopes []Scope
+		need   Scope
+		want   bool
+	}{
+		{[]Scope{ScopeRead}, ScopeRead, true},
+		{[]Scope{ScopeRead}, ScopeWrite, false},
+		{[]Scope{ScopeWrite}, ScopeRead, true},
+		{[]Scope{ScopeWrite}, ScopeAdmin, false},
+		{[]Scope{ScopeAdmin}, ScopeWrite, true},
+		{[]Scope{ScopeRead, ScopeAdmin}, ScopeAdmin, true},
+		{nil, ScopeRead, false},
+	}
+	for _, tt := range tests {
+		p := &Principal{Scopes: tt.scopes}
+		if got := p.Has(tt.need); got != tt.want {
+			t.Errorf("%v has %s = %v, want %v", tt.scopes, tt.need, got, tt.want)
+		}
+	}
+}
+
+func TestTenancy(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *Principal
+		client    int64
+		want      bool
+	}{
+		{"no principal", nil, 7, true},
+		{"admin", &Principal{Role: RoleAdmin}, 7, true},
+		{"service", &Principal{Role: RoleService}, 7, true},
+		{"support own client", &Principal{Role: RoleSupport, ClientIDs: []int64{5, 7}}, 7, true},
+		{"support other client", &Principal{Role: RoleSupport, ClientIDs: []int64{5}}, 7, false},
+		{"partner other client", &Principal{Role: RolePartner, ClientIDs: []int64{5}}, 7, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.principal.CanAccessClient(tt.client); got != tt.want {
+				t.Fatalf("CanAccessClient(%d) = %v, want %v", tt.client, got, tt.want)
+			}
+		})
+	}
+
+	if got := Actor(WithPrincipal(context.Background(), &Principal{Method: "api_key", Subject: "ci"})); got != "api_key:ci" {
+		t.Errorf("Actor = %q", got)
+	}
+	if got := Actor(context.Background()); got != "anonymous" {
+		t.Errorf("Actor without principal = %q", got)
+	}
+}
+
+func TestCheckTenancy(t *testing.T) {
+	tests := []struct {
+		role    Role
+		clients []int64
+		wantErr bool
+	}{
+		{RoleAdmin, nil, false},
+		{RoleAdmin, []int64{1}, true},
+		{RoleService, nil, false},
+		{RoleSupport, []int64{1, 2}, false},
+		{RoleSupport, nil, true},
+		{RolePartner, []int64{1}, false},
+		{RolePartner, []int64{1, 2}, true},
+		{RolePartner, nil, true},
+		{"", nil, true},
+	}
+	for _, tt := range tests {
+		if err := CheckTenancy(tt.role, tt.clients); (err != nil) != tt.wantErr {
+			t.Errorf("CheckTenancy(%q, %v) = %v, wantErr %v", tt.role, tt.clients, err, tt.wantErr)
+		}
+	}
+}
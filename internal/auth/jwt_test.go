@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func b64(n *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(n.Bytes())
+}
+
+func rsaJWK(kid string, key *rsa.PrivateKey) jwk {
+	return jwk{Kty: "RSA", Kid: kid, Use: "sig", N: b64(key.N), E: b64(big.NewInt(int64(key.E)))}
+}
+
+func ecJWK(kid string, key *ecdsa.PrivateKey) jwk {
+	return jwk{Kty: "EC", Kid: kid, Crv: "P-256", X: b64(key.X), Y: b64(key.Y)}
+}
+
+func jwksFile(t *testing.T, keys ...jwk) string {
+	t.Helper()
+	data, err := json.Marshal(map[string][]jwk{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeFile(t, "jwks.json", string(data))
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func claims(overrides jwt.MapClaims) jwt.MapClaims {
+	c := jwt.MapClaims{
+		"sub":   "billing",
+		"iss":   "https://issuer.example",
+		"aud":   "order-service",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "read write other:scope",
+		"role":  "service",
+	}
+	for k, v := range overrides {
+		if v == nil {
+			delete(c, k)
+			continue
+		}
+		c[k] = v
+	}
+	return c
+}
+
+func TestJWTAuthentication(t *testing.T) {
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	strangerKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	// Ротация: в JWKS опубликованы старый и новый ключи
+	file := jwksFile(t, rsaJWK("old", oldKey), ecJWK("new", newKey))
+	a, err := New(Config{JWKSFile: file, JWTIssuer: "https://issuer.example", JWTAudience: "order-service"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// HS256 с публичным ключом в роли секрета - классическая подмена алгоритма
+	publicBytes := oldKey.N.Bytes()
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"old key RS256", sign(t, jwt.SigningMethodRS256, "old", oldKey, claims(nil)), true},
+		{"new key ES256", sign(t, jwt.SigningMethodES256, "new", newKey, claims(nil)), true},
+		{"PS256", sign(t, jwt.SigningMethodPS256, "old", oldKey, claims(nil)), true},
+		{"unknown kid", sign(t, jwt.SigningMethodRS256, "gone", oldKey, claims(nil)), false},
+		{"no kid with several keys", sign(t, jwt.SigningMethodRS256, "", oldKey, claims(nil)), false},
+		{"foreign key", sign(t, jwt.SigningMethodRS256, "old", strangerKey, claims(nil)), false},
+		{"HS256", sign(t, jwt.SigningMethodHS256, "old", publicBytes, claims(nil)), false},
+		{"alg none", sign(t, jwt.SigningMethodNone, "old", jwt.UnsafeAllowNoneSignatureType, claims(nil)), false},
+		{"expired", sign(t, jwt.SigningMethodRS256, "old", oldKey, claims(jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()})), false},
+		{"no exp", sign(t, jwt.SigningMethodRS256, "old", oldKey, claims(jwt.MapClaims{"exp": nil})), false},
+		{"not yet valid", sign(t, jwt.SigningMethodRS256, "old", oldKey, claims(jwt.MapClaims{"nbf": time.Now().Add(time.Hour).Unix()})), false},
+		{"wrong issuer", sign(t, jwt.SigningMethodRS256, "old", oldKey, claims(jwt.MapClaims{"iss": "https://evil.example"})), false},
+		{"wrong audience", sign(t, jwt.SigningMethodRS256, "old", oldKey, claims(jwt.MapClaims{"aud": "billing"})), false},
+		{"no subject", sign(t, jwt.SigningMethodRS256, "old", oldKey, claims(jwt.MapClaims{"sub": nil})), false},
+		{"partner without client", sign(t, jwt.SigningMethodRS256, "old", oldKey, claims(jwt.MapClaims{"role": "partner"})), false},
+		{"unknown role", sign(t, jwt.SigningMethodRS256, "old", oldKey, claims(jwt.MapClaims{"role": "root"})), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := a.AuthenticateHeaders("Bearer "+tt.token, "")
+			if !tt.ok {
+				if !errors.Is(err, ErrInvalidCredentials) {
+					t.Fatalf("err = %v, want ErrInvalidCredentials", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Незнакомые права других сервисов отбрасываются
+			if p.Subject != "billing" || p.Method != "jwt" || p.Role != RoleService ||
+				len(p.Scopes) != 2 || !p.Has(ScopeWrite) || p.Has(ScopeAdmin) {
+				t.Fatalf("principal = %+v", p)
+			}
+		})
+	}
+}
+
+func TestJWTClaims(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	a, err := New(Config{JWKSFile: jwksFile(t, rsaJWK("k1", key))})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// scp массивом и партнер с единственным client_id; kid не нужен при одном ключе
+	raw := sign(t, jwt.SigningMethodRS256, "", key, claims(jwt.MapClaims{
+		"scope": nil, "scp": []string{"admin"}, "role": "partner", "client_id": 42,
+	}))
+	p, err := a.AuthenticateHeaders("Bearer "+raw, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Has(ScopeAdmin) || p.Role != RolePartner || !p.CanAccessClient(42) || p.CanAccessClient(43) {
+		t.Fatalf("principal = %+v", p)
+	}
+}
+
+// TestJWKSKeyRemoved проверяет, что после удаления старого ключа из JWKS
+// подписанные им токены перестают приниматься
+func TestJWKSKeyRemoved(t *testing.T) {
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	a, err := New(Config{JWKSFile: jwksFile(t, rsaJWK("new", newKey))})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.AuthenticateHeaders("Bearer "+sign(t, jwt.SigningMethodRS256, "old", oldKey, claims(nil)), ""); err == nil {
+		t.Error("token signed with a removed key accepted")
+	}
+	if _, err := a.AuthenticateHeaders("Bearer "+sign(t, jwt.SigningMethodRS256, "new", newKey, claims(nil)), ""); err != nil {
+		t.Errorf("token signed with the current key: %v", err)
+	}
+}
+
+func TestJWKSRejects(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	enc := rsaJWK("enc", key)
+	enc.Use = "enc"
+
+	tests := []struct {
+		name string
+		keys []jwk
+	}{
+		{"empty", nil},
+		{"only encryption keys", []jwk{enc}},
+		{"unsupported kty", []jwk{{Kty: "oct", Kid: "k"}}},
+		{"unsupported curve", []jwk{{Kty: "EC", Kid: "k", Crv: "P-192", X: "AQ", Y: "AQ"}}},
+		{"bad exponent", []jwk{{Kty: "RSA", Kid: "k", N: b64(key.N), E: "AQ"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(Config{JWKSFile: jwksFile(t, tt.keys...)}); err == nil {
+				t.Fatal("invalid JWKS accepted")
+			}
+		})
+	}
+}
@@ -1,64 +1,238 @@
 package cache
 
 import (
+	"hash/maphash"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"order-service/internal/models"
 )
 
+// Сколько помнить, что заказа нет в БД
+const defaultNegativeTTL = 30 * time.Second
+
+// При таком числе отрицательных записей в шарде просроченные вычищаются
+const negativeSweepThreshold = 1024
+
+// Число шардов по умолчанию. Степень двойки, чтобы номер шарда брался маской
+const defaultShards = 64
+
+// Cache хранит собственные копии заказов: Set и LoadFromSlice копируют входные
+// данные, Get и GetAll отдают копии, поэтому изменения у вызывающей стороны
+// не портят кэш и не гоняются с другими читателями.
+//
+// Ключи распределены по шардам с отдельными блокировками, так что запись
+// блокирует только читателей своего шарда
 type Cache struct {
+	shards []*shard
+	mask   uint64
+	seed   maphash.Seed
+
+	negativeTTL atomic.Int64
+
+	snapshotCipher   SnapshotCipher
+	snapshotRequests chan struct{}
+
+	hits         atomic.Uint64
+	misses       atomic.Uint64
+	negativeHits atomic.Uint64
+	evictions    atomic.Uint64
+}
+
+type Stats struct {
+	Hits         uint64  `json:"hits"`
+	Misses       uint64  `json:"misses"`
+	NegativeHits uint64  `json:"negative_hits"`
+	Evictions    uint64  `json:"evictions"`
+	Size         int     `json:"size"`
+	HitRatio     float64 `json:"hit_ratio"`
+}
+
+type shard struct {
 	mu     sync.RWMutex
 	orders map[string]*models.Order
+
+	// Отрицательный кэш: order_id -> момент, до которого считаем заказ несуществующим
+	missing map[string]time.Time
 }
 
 func NewCache() *Cache {
-	return &Cache{
-		orders: make(map[string]*models.Order),
+	return NewCacheWithShards(defaultShards)
+}
+
+// NewCacheWithShards создает кэш с заданным числом шардов, округленным вверх до степени двойки
+func NewCacheWithShards(n int) *Cache {
+	size := 1
+	for size < n {
+		size <<= 1
 	}
+
+	c := &Cache{
+		shards: make([]*shard, size),
+		mask:   uint64(size - 1),
+		seed:   maphash.MakeSeed(),
+
+		snapshotRequests: make(chan struct{}, 1),
+	}
+	for i := range c.shards {
+		c.shards[i] = newShard()
+	}
+	c.negativeTTL.Store(int64(defaultNegativeTTL))
+	return c
+}
+
+func newShard() *shard {
+	return &shard{
+		orders:  make(map[string]*models.Order),
+		missing: make(map[string]time.Time),
+	}
+}
+
+func (c *Cache) shardFor(orderID string) *shard {
+	return c.shards[maphash.String(c.seed, orderID)&c.mask]
+}
+
+// SetNegativeTTL задает время жизни отрицательных записей, 0 отключает их
+func (c *Cache) SetNegativeTTL(ttl time.Duration) {
+	c.negativeTTL.Store(int64(ttl))
 }
 
 func (c *Cache) Set(order *models.Order) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.orders[order.OrderID] = order
+	clone := order.Clone()
+	s := c.shardFor(order.OrderID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.orders[order.OrderID] = clone
+	delete(s.missing, order.OrderID)
+}
+
+// SetMissing запоминает, что заказа нет в БД
+func (c *Cache) SetMissing(orderID string) {
+	ttl := time.Duration(c.negativeTTL.Load())
+	if ttl <= 0 {
+		return
+	}
+
+	s := c.shardFor(orderID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if len(s.missing) >= negativeSweepThreshold {
+		for id, expires := range s.missing {
+			if now.After(expires) {
+				delete(s.missing, id)
+			}
+		}
+	}
+	s.missing[orderID] = now.Add(ttl)
+}
+
+// IsMissing сообщает, что заказ недавно не был найден в БД
+func (c *Cache) IsMissing(orderID string) bool {
+	s := c.shardFor(orderID)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	expires, exists := s.missing[orderID]
+	missing := exists && time.Now().Before(expires)
+	if missing {
+		c.negativeHits.Add(1)
+	}
+	return missing
 }
 
 func (c *Cache) Get(orderID string) (*models.Order, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	s := c.shardFor(orderID)
+	s.mu.RLock()
+	order, exists := s.orders[orderID]
+	s.mu.RUnlock()
 
-	order, exists := c.orders[orderID]
-	return order, exists
+	if exists {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+
+	// Закэшированные значения не меняются на месте, поэтому копируем уже без блокировки
+	return order.Clone(), exists
+}
+
+// Contains проверяет наличие заказа, не влияя на статистику попаданий
+func (c *Cache) Contains(orderID string) bool {
+	s := c.shardFor(orderID)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, exists := s.orders[orderID]
+	return exists
 }
 
 func (c *Cache) GetAll() []*models.Order {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	orders := make([]*models.Order, 0, c.Len())
+	for _, s := range c.shards {
+		s.mu.RLock()
+		for _, order := range s.orders {
+			orders = append(orders, order)
+		}
+		s.mu.RUnlock()
+	}
 
-	orders := make([]*models.Order, 0, len(c.orders))
-	for _, order := range c.orders {
-		orders = append(orders, order)
+	for i, order := range orders {
+		orders[i] = order.Clone()
 	}
 	return orders
 }
 
+// Len возвращает число закэшированных заказов
+func (c *Cache) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		s.mu.RLock()
+		n += len(s.orders)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
 func (c *Cache) Delete(orderID string) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	delete(c.orders, orderID)
+	s := c.shardFor(orderID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.orders[orderID]; exists {
+		c.evictions.Add(1)
+	}
+	delete(s.orders, orderID)
+	delete(s.missing, orderID)
 }
 
 func (c *Cache) LoadFromSlice(orders []models.Order) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
 	for i := range orders {
-		c.orders[orders[i].OrderID] = &orders[i]
+		c.Set(&orders[i])
 	}
 }
 
 func (c *Cache) Clear() {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.orders = make(map[string]*models.Order)
+	for _, s := range c.shards {
+		s.mu.Lock()
+		c.evictions.Add(uint64(len(s.orders)))
+		s.orders = make(map[string]*models.Order)
+		s.missing = make(map[string]time.Time)
+		s.mu.Unlock()
+	}
+}
+
+func (c *Cache) Stats() Stats {
+	stats := Stats{
+		Hits:         c.hits.Load(),
+		Misses:       c.misses.Load(),
+		NegativeHits: c.negativeHits.Load(),
+		Evictions:    c.evictions.Load(),
+		Size:         c.Len(),
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(total)
+	}
+	return stats
 }
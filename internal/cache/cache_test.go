@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"order-service/internal/models"
+)
+
+func testOrder(id string, clientID int64) *models.Order {
+	return &models.Order{
+		OrderID:     id,
+		ClientID:    clientID,
+		Delivery:    models.Delivery{Name: "Test Testov", City: "Moscow"},
+		Items:       []models.Product{{ProductID: 1, Name: "item", Quantity: 1}},
+		DateCreated: time.Now(),
+	}
+}
+
+func TestGetReturnsCopy(t *testing.T) {
+	c := NewCache()
+	order := testOrder("o1", 1)
+	c.Set(order)
+
+	// Изменение исходного заказа после Set не должно попасть в кэш
+	order.Items[0].Name = "changed by caller"
+
+	got, ok := c.Get("o1")
+	if !ok {
+		t.Fatal("order not found")
+	}
+	if got.Items[0].Name != "item" {
+		t.Fatalf("cache shares items with caller: %q", got.Items[0].Name)
+	}
+
+	got.Delivery.Name = "changed by reader"
+	got.Items[0].Quantity = 100
+	again, _ := c.Get("o1")
+	if again.Delivery.Name != "Test Testov" || again.Items[0].Quantity != 1 {
+		t.Fatalf("cache shares order with reader: %+v", again)
+	}
+}
+
+// TestConcurrentAccess гоняет чтение, запись и удаление параллельно и меняет
+// полученные копии. Смысл теста - в запуске под go test -race
+func TestConcurrentAccess(t *testing.T) {
+	c := NewCacheWithShards(4)
+	const (
+		workers    = 8
+		iterations = 500
+		keys       = 32
+	)
+
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(5)
+
+		go func() {
+			defer wg.Done()
+			for i := range iterations {
+				order := testOrder(fmt.Sprintf("o%d", i%keys), int64(w))
+				c.Set(order)
+				// Вызывающая сторона продолжает менять свой заказ
+				order.Items[0].Quantity = i
+				order.Delivery.Name = "mutated"
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+			for i := range iterations {
+				if order, ok := c.Get(fmt.Sprintf("o%d", i%keys)); ok {
+					order.Items[0].Quantity++
+					order.Items = append(order.Items, models.Product{ProductID: int64(i)})
+					order.Delivery.City = "mutated"
+				}
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+			for i := range iterations {
+				if i%3 == 0 {
+					c.Delete(fmt.Sprintf("o%d", (i+w)%keys))
+				} else {
+					c.SetMissing(fmt.Sprintf("missing%d", i%keys))
+					c.IsMissing(fmt.Sprintf("missing%d", i%keys))
+				}
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+			for range iterations / 10 {
+				for _, order := range c.GetAll() {
+					order.Items = nil
+					order.Locale = "mutated"
+				}
+				c.Stats()
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+			for i := range iterations / 10 {
+				batch := make([]models.Order, 0, 4)
+				for j := range 4 {
+					batch = append(batch, *testOrder(fmt.Sprintf("o%d", (i+j)%keys), int64(w)))
+				}
+				c.LoadFromSlice(batch)
+				batch[0].Items[0].Name = "mutated"
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Ни одна из правок копий не должна была попасть в кэш
+	for _, order := range c.GetAll() {
+		if order.Locale == "mutated" || order.Delivery.City == "mutated" || order.Delivery.Name == "mutated" {
+			t.Fatalf("cached order was modified through a copy: %+v", order)
+		}
+		if len(order.Items) != 1 || order.Items[0].Name != "item" {
+			t.Fatalf("cached items were modified through a copy: %+v", order.Items)
+		}
+	}
+}
+
+// benchmarkMixed - параллельная нагрузка, в которой writePercent процентов
+// операций - запись, остальное - чтение. shards=1 соответствует прежнему кэшу
+// с одной блокировкой
+func benchmarkMixed(b *testing.B, shards, writePercent int) {
+	const keys = 10000
+	c := NewCacheWithShards(shards)
+	ids := make([]string, keys)
+	orders := make([]*models.Order, keys)
+	for i := range keys {
+		ids[i] = fmt.Sprintf("order%d", i)
+		orders[i] = testOrder(ids[i], int64(i))
+		c.Set(orders[i])
+	}
+
+	// У каждой горутины свой генератор ключей, иначе они обходят ключи в
+	// одном порядке и одновременно попадают в один шард
+	var seed atomic.Uint64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		rng := rand.New(rand.NewPCG(seed.Add(1), 0))
+		i := 0
+		for pb.Next() {
+			k := rng.IntN(keys)
+			if i%100 < writePercent {
+				c.Set(orders[k])
+			} else {
+				c.Get(ids[k])
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkCacheMixed(b *testing.B) {
+	for _, shards := range []int{1, defaultShards} {
+		for _, writes := range []int{10, 50} {
+			b.Run(fmt.Sprintf("shards=%d/writes=%d%%", shards, writes), func(b *testing.B) {
+				benchmarkMixed(b, shards, writes)
+			})
+		}
+	}
+}
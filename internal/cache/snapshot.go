@@ -0,0 +1,165 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"order-service/internal/models"
+)
+
+// Формат файла: magic (4 байта) | CRC32 полезной нагрузки (4 байта, big endian) | полезная нагрузка.
+// Полезная нагрузка - gob(snapshot), зашифрованный SnapshotCipher, если он задан
+var snapshotMagic = [4]byte{'O', 'C', 'S', '1'}
+
+const snapshotHeaderSize = 8
+
+// snapshotAAD привязывает шифртекст к файлу снимка, чтобы его нельзя было подставить в поле заказа
+const snapshotAAD = "cache-snapshot"
+
+// SnapshotCipher шифрует снимок кэша: в нем лежат заказы с расшифрованными
+// персональными данными доставки. Реализуется *pii.Keyring. Decrypt должен
+// возвращать незашифрованное значение как есть, чтобы читались старые снимки
+type SnapshotCipher interface {
+	Encrypt(plaintext, aad string) (string, error)
+	Decrypt(value, aad string) (string, error)
+}
+
+// SetSnapshotCipher задает шифрование снимков. Без него снимок пишется открытым
+func (c *Cache) SetSnapshotCipher(cipher SnapshotCipher) {
+	c.snapshotCipher = cipher
+}
+
+// RequestSnapshot просит RunSnapshots сохранить снимок вне расписания, например
+// после обезличивания данных, чтобы удаленные из кэша заказы не оставались в файле
+func (c *Cache) RequestSnapshot() {
+	select {
+	case c.snapshotRequests <- struct{}{}:
+	default:
+	}
+}
+
+type snapshot struct {
+	SavedAt time.Time
+	Orders  []models.Order
+}
+
+// SaveSnapshot атомарно записывает содержимое кэша в файл и возвращает число сохраненных заказов
+func (c *Cache) SaveSnapshot(path string) (int, error) {
+	orders := c.GetAll()
+	snap := snapshot{SavedAt: time.Now(), Orders: make([]models.Order, 0, len(orders))}
+	for _, order := range orders {
+		snap.Orders = append(snap.Orders, *order)
+	}
+
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(snap); err != nil {
+		return 0, fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	if c.snapshotCipher != nil {
+		sealed, err := c.snapshotCipher.Encrypt(payload.String(), snapshotAAD)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encrypt snapshot: %w", err)
+		}
+		payload.Reset()
+		payload.WriteString(sealed)
+	}
+
+	header := make([]byte, snapshotHeaderSize)
+	copy(header, snapshotMagic[:])
+	binary.BigEndian.PutUint32(header[4:], crc32.ChecksumIEEE(payload.Bytes()))
+
+	// Пишем во временный файл рядом и переименовываем, чтобы не оставить битый снимок
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(header); err == nil {
+		_, err = tmp.Write(payload.Bytes())
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, fmt.Errorf("failed to replace snapshot: %w", err)
+	}
+
+	return len(snap.Orders), nil
+}
+
+// LoadSnapshot загружает заказы из файла снимка в кэш. Если файла нет, ошибка оборачивает os.ErrNotExist
+func (c *Cache) LoadSnapshot(path string) (int, time.Time, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	if len(data) < snapshotHeaderSize || !bytes.Equal(data[:4], snapshotMagic[:]) {
+		return 0, time.Time{}, errors.New("snapshot has invalid header")
+	}
+
+	payload := data[snapshotHeaderSize:]
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(data[4:snapshotHeaderSize]) {
+		return 0, time.Time{}, errors.New("snapshot checksum mismatch")
+	}
+
+	if c.snapshotCipher != nil {
+		plain, err := c.snapshotCipher.Decrypt(string(payload), snapshotAAD)
+		if err != nil {
+			return 0, time.Time{}, fmt.Errorf("failed to decrypt snapshot: %w", err)
+		}
+		payload = []byte(plain)
+	}
+
+	var snap snapshot
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&snap); err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	c.LoadFromSlice(snap.Orders)
+	return len(snap.Orders), snap.SavedAt, nil
+}
+
+// RunSnapshots сохраняет снимок кэша каждые interval, по RequestSnapshot и последний раз при отмене ctx
+func (c *Cache) RunSnapshots(ctx context.Context, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	save := func() {
+		n, err := c.SaveSnapshot(path)
+		if err != nil {
+			slog.Warn("failed to save cache snapshot", "path", path, "error", err)
+			return
+		}
+		slog.Info("cache snapshot saved", "path", path, "orders", n)
+	}
+
+	for {
+		select {
+		case <-ticker.C:
+			save()
+		case <-c.snapshotRequests:
+			save()
+		case <-ctx.Done():
+			save()
+			return
+		}
+	}
+}
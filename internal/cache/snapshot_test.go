@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"order-service/internal/pii"
+)
+
+func testKeyring(t *testing.T, keys, active string) *pii.Keyring {
+	t.Helper()
+	index := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{9}, 32))
+	k, err := pii.NewKeyring(keys, active, index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+func TestSnapshotEncrypted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snap")
+	keyring := testKeyring(t, "k1:"+testKey(1), "")
+
+	src := NewCache()
+	src.SetSnapshotCipher(keyring)
+	order := testOrder("o1", 1)
+	order.Delivery.Email = "secret@example.com"
+	src.Set(order)
+	if _, err := src.SaveSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, plain := range []string{"secret@example.com", "Test Testov"} {
+		if bytes.Contains(data, []byte(plain)) {
+			t.Errorf("snapshot contains plaintext %q", plain)
+		}
+	}
+
+	// После ротации старый ключ остается в наборе и снимок читается
+	dst := NewCache()
+	dst.SetSnapshotCipher(testKeyring(t, "k2:"+testKey(2)+",k1:"+testKey(1), "k2"))
+	n, _, err := dst.LoadSnapshot(path)
+	if err != nil || n != 1 {
+		t.Fatalf("LoadSnapshot = %d, %v", n, err)
+	}
+	got, ok := dst.Get("o1")
+	if !ok || got.Delivery.Email != "secret@example.com" {
+		t.Fatalf("restored order = %+v, %v", got, ok)
+	}
+
+	// Без ключа зашифрованный снимок не загружается
+	for name, cipher := range map[string]SnapshotCipher{
+		"no keys":   nil,
+		"wrong key": testKeyring(t, "k1:"+testKey(3), ""),
+	} {
+		c := NewCache()
+		if cipher != nil {
+			c.SetSnapshotCipher(cipher)
+		}
+		if _, _, err := c.LoadSnapshot(path); err == nil {
+			t.Errorf("%s: encrypted snapshot loaded", name)
+		}
+		if c.Len() != 0 {
+			t.Errorf("%s: cache has %d orders", name, c.Len())
+		}
+	}
+}
+
+// TestSnapshotPlaintextUpgrade проверяет, что снимок, записанный до включения
+// шифрования, загружается и при следующем сохранении шифруется
+func TestSnapshotPlaintextUpgrade(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snap")
+	src := NewCache()
+	src.Set(testOrder("o1", 1))
+	if _, err := src.SaveSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCache()
+	c.SetSnapshotCipher(testKeyring(t, "k1:"+testKey(1), ""))
+	if n, _, err := c.LoadSnapshot(path); err != nil || n != 1 {
+		t.Fatalf("LoadSnapshot = %d, %v", n, err)
+	}
+	if _, err := c.SaveSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data[snapshotHeaderSize:]), "enc:v1:k1:") {
+		t.Fatal("snapshot is not encrypted after re-save")
+	}
+}
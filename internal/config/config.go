@@ -0,0 +1,278 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"order-service/internal/ratelimit"
+)
+
+// Config - итоговая конфигурация сервиса. Тег env задает переменную окружения,
+// из нее же выводится имя флага (DB_HOST -> -db-host). Поля с secret:"true"
+// скрываются при выводе
+type Config struct {
+	Server    ServerConfig    `yaml:"server" toml:"server"`
+	Database  DatabaseConfig  `yaml:"database" toml:"database"`
+	Cache     CacheConfig     `yaml:"cache" toml:"cache"`
+	Auth      AuthConfig      `yaml:"auth" toml:"auth"`
+	PII       PIIConfig       `yaml:"pii" toml:"pii"`
+	RateLimit RateLimitConfig `yaml:"rate_limit" toml:"rate_limit"`
+	Log       LogConfig       `yaml:"log" toml:"log"`
+	Tracing   TracingConfig   `yaml:"tracing" toml:"tracing"`
+}
+
+type ServerConfig struct {
+	Port            int           `yaml:"port" toml:"port" env:"SERVER_PORT"`
+	TLSCertFile     string        `yaml:"tls_cert_file" toml:"tls_cert_file" env:"SERVER_TLS_CERT_FILE"`
+	TLSKeyFile      string        `yaml:"tls_key_file" toml:"tls_key_file" env:"SERVER_TLS_KEY_FILE"`
+	ReadTimeout     time.Duration `yaml:"read_timeout" toml:"read_timeout" env:"SERVER_READ_TIMEOUT"`
+	WriteTimeout    time.Duration `yaml:"write_timeout" toml:"write_timeout" env:"SERVER_WRITE_TIMEOUT"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT"`
+	ShutdownDelay   time.Duration `yaml:"shutdown_delay" toml:"shutdown_delay" env:"SHUTDOWN_DELAY"`
+	MaxBodyBytes    int           `yaml:"max_body_bytes" toml:"max_body_bytes" env:"SERVER_MAX_BODY_BYTES"`
+	// Порт gRPC API, 0 - gRPC выключен
+	GRPCPort int `yaml:"grpc_port" toml:"grpc_port" env:"SERVER_GRPC_PORT"`
+}
+
+type DatabaseConfig struct {
+	Host            string        `yaml:"host" toml:"host" env:"DB_HOST"`
+	Port            int           `yaml:"port" toml:"port" env:"DB_PORT"`
+	User            string        `yaml:"user" toml:"user" env:"DB_USER"`
+	Password        string        `yaml:"password" toml:"password" env:"DB_PASSWORD" secret:"true"`
+	Name            string        `yaml:"name" toml:"name" env:"DB_NAME"`
+	SSLMode         string        `yaml:"sslmode" toml:"sslmode" env:"DB_SSLMODE"`
+	SSLRootCert     string        `yaml:"sslrootcert" toml:"sslrootcert" env:"DB_SSLROOTCERT"`
+	SSLCert         string        `yaml:"sslcert" toml:"sslcert" env:"DB_SSLCERT"`
+	SSLKey          string        `yaml:"sslkey" toml:"sslkey" env:"DB_SSLKEY"`
+	ConnectTimeout  time.Duration `yaml:"connect_timeout" toml:"connect_timeout" env:"DB_CONNECT_TIMEOUT"`
+	MaxConns        int           `yaml:"max_conns" toml:"max_conns" env:"DB_MAX_CONNS"`
+	MinConns        int           `yaml:"min_conns" toml:"min_conns" env:"DB_MIN_CONNS"`
+	MaxConnLifetime time.Duration `yaml:"max_conn_lifetime" toml:"max_conn_lifetime" env:"DB_MAX_CONN_LIFETIME"`
+	MaxConnIdleTime time.Duration `yaml:"max_conn_idle_time" toml:"max_conn_idle_time" env:"DB_MAX_CONN_IDLE_TIME"`
+}
+
+type CacheConfig struct {
+	Shards                   int           `yaml:"shards" toml:"shards" env:"CACHE_SHARDS"`
+	NegativeTTL              time.Duration `yaml:"negative_ttl" toml:"negative_ttl" env:"CACHE_NEGATIVE_TTL"`
+	SnapshotPath             string        `yaml:"snapshot_path" toml:"snapshot_path" env:"CACHE_SNAPSHOT_PATH"`
+	SnapshotInterval         time.Duration `yaml:"snapshot_interval" toml:"snapshot_interval" env:"CACHE_SNAPSHOT_INTERVAL"`
+	WarmupStrategy           string        `yaml:"warmup_strategy" toml:"warmup_strategy" env:"CACHE_WARMUP_STRATEGY"`
+	WarmupDays               int           `yaml:"warmup_days" toml:"warmup_days" env:"CACHE_WARMUP_DAYS"`
+	WarmupLimit              int           `yaml:"warmup_limit" toml:"warmup_limit" env:"CACHE_WARMUP_LIMIT"`
+	WarmupBatch              int           `yaml:"warmup_batch" toml:"warmup_batch" env:"CACHE_WARMUP_BATCH"`
+	AccessStatsFlushInterval time.Duration `yaml:"access_stats_flush_interval" toml:"access_stats_flush_interval" env:"ACCESS_STATS_FLUSH_INTERVAL"`
+}
+
+// AuthConfig - источники учетных данных. Сервер без них не запускается,
+// если аутентификация не выключена явно через Disabled
+type AuthConfig struct {
+	APIKeysFile string `yaml:"api_keys_file" toml:"api_keys_file" env:"AUTH_API_KEYS_FILE"`
+	JWKSFile    string `yaml:"jwks_file" toml:"jwks_file" env:"AUTH_JWKS_FILE"`
+	JWTIssuer   string `yaml:"jwt_issuer" toml:"jwt_issuer" env:"AUTH_JWT_ISSUER"`
+	JWTAudience string `yaml:"jwt_audience" toml:"jwt_audience" env:"AUTH_JWT_AUDIENCE"`
+	// Открыть API без аутентификации, например для локальной разработки
+	Disabled bool `yaml:"disabled" toml:"disabled" env:"AUTH_DISABLED"`
+}
+
+// PIIConfig - защита персональных данных доставки
+type PIIConfig struct {
+	// Ключи AES-256 вида "k2:<base64>,k1:<base64>", пусто - без шифрования
+	EncryptionKeys string `yaml:"encryption_keys" toml:"encryption_keys" env:"PII_ENCRYPTION_KEYS" secret:"true"`
+	// Ключ для новых записей, по умолчанию первый в списке
+	ActiveKey string `yaml:"active_key" toml:"active_key" env:"PII_ACTIVE_KEY"`
+	// Base64-ключ HMAC для поиска по email, обязателен при шифровании
+	BlindIndexKey string `yaml:"blind_index_key" toml:"blind_index_key" env:"PII_BLIND_INDEX_KEY" secret:"true"`
+	// Роли через запятую, которым данные отдаются без маскирования
+	UnmaskedRoles string `yaml:"unmasked_roles" toml:"unmasked_roles" env:"PII_UNMASKED_ROLES"`
+}
+
+// RateLimitConfig - лимиты частоты запросов к API в формате ratelimit.ParseLimit.
+// Лимит считается отдельно для каждого маршрута и клиента: аутентифицированного -
+// по API-ключу или sub из JWT, анонимного - по IP. Методы gRPC ограничиваются
+// лимитом default и тем же лимитом неудачных попыток аутентификации
+type RateLimitConfig struct {
+	Enabled bool   `yaml:"enabled" toml:"enabled" env:"RATE_LIMIT_ENABLED"`
+	Default string `yaml:"default" toml:"default" env:"RATE_LIMIT_DEFAULT"`
+	// Лимит неудачных попыток аутентификации с одного IP. Исчерпав его,
+	// клиент получает 429 до проверки учетных данных
+	AuthFailures string `yaml:"auth_failures" toml:"auth_failures" env:"RATE_LIMIT_AUTH_FAILURES"`
+	// Лимиты отдельных маршрутов, например
+	// RATE_LIMIT_ROUTES="POST /api/v1/orders=10/s:20;GET /api/v1/orders:export=1/m"
+	Routes map[string]string `yaml:"routes" toml:"routes" env:"RATE_LIMIT_ROUTES"`
+	// Брать IP клиента из X-Forwarded-For. Включать только за доверенным прокси
+	TrustProxy bool `yaml:"trust_proxy" toml:"trust_proxy" env:"RATE_LIMIT_TRUST_PROXY"`
+	// Число доверенных прокси перед сервисом. Каждый дописывает адрес в конец
+	// X-Forwarded-For, поэтому IP клиента берется на столько позиций с конца
+	TrustedProxies int `yaml:"trusted_proxies" toml:"trusted_proxies" env:"RATE_LIMIT_TRUSTED_PROXIES"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level" toml:"level" env:"LOG_LEVEL"`
+	Format string `yaml:"format" toml:"format" env:"LOG_FORMAT"`
+}
+
+type TracingConfig struct {
+	Exporter string `yaml:"exporter" toml:"exporter" env:"OTEL_TRACES_EXPORTER"`
+}
+
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Port:            8080,
+			GRPCPort:        9090,
+			ReadTimeout:     10 * time.Second,
+			WriteTimeout:    10 * time.Second,
+			IdleTimeout:     60 * time.Second,
+			ShutdownTimeout: 30 * time.Second,
+			MaxBodyBytes:    1 << 20,
+		},
+		Database: DatabaseConfig{
+			Host:           "localhost",
+			Port:           5432,
+			SSLMode:        "disable",
+			ConnectTimeout: 5 * time.Second,
+			MaxConns:       10,
+		},
+		Cache: CacheConfig{
+			Shards:                   64,
+			NegativeTTL:              30 * time.Second,
+			SnapshotInterval:         5 * time.Minute,
+			WarmupStrategy:           "latest",
+			WarmupDays:               7,
+			WarmupLimit:              100,
+			WarmupBatch:              500,
+			AccessStatsFlushInterval: time.Minute,
+		},
+		PII: PIIConfig{
+			UnmaskedRoles: "admin,service,partner",
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:        true,
+			Default:        "20/s:40",
+			AuthFailures:   "10/m:20",
+			TrustedProxies: 1,
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Tracing: TracingConfig{
+			Exporter: "none",
+		},
+	}
+}
+
+var sslModes = map[string]bool{
+	"disable": true, "allow": true, "prefer": true,
+	"require": true, "verify-ca": true, "verify-full": true,
+}
+
+// Validate проверяет значения и возвращает все найденные ошибки разом
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port < 65536, "server.port must be between 1 and 65535, got %d", c.Server.Port)
+	check(c.Server.GRPCPort >= 0 && c.Server.GRPCPort < 65536, "server.grpc_port must be between 0 and 65535, got %d", c.Server.GRPCPort)
+	check(c.Server.GRPCPort != c.Server.Port, "server.grpc_port must differ from server.port")
+	check((c.Server.TLSCertFile == "") == (c.Server.TLSKeyFile == ""), "server.tls_cert_file and server.tls_key_file must be set together")
+	check(c.Server.ReadTimeout > 0, "server.read_timeout must be positive")
+	check(c.Server.WriteTimeout > 0, "server.write_timeout must be positive")
+	check(c.Server.IdleTimeout > 0, "server.idle_timeout must be positive")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+	check(c.Server.ShutdownDelay >= 0, "server.shutdown_delay must not be negative")
+	check(c.Server.MaxBodyBytes > 0, "server.max_body_bytes must be positive")
+
+	check(c.Database.Host != "", "database.host is required")
+	check(c.Database.Port > 0 && c.Database.Port < 65536, "database.port must be between 1 and 65535, got %d", c.Database.Port)
+	check(c.Database.User != "", "database.user is required")
+	check(c.Database.Name != "", "database.name is required")
+	check(sslModes[c.Database.SSLMode], "database.sslmode %q is not supported", c.Database.SSLMode)
+	check(c.Database.ConnectTimeout >= 0, "database.connect_timeout must not be negative")
+	check(c.Database.MaxConns > 0, "database.max_conns must be positive")
+	check(c.Database.MinConns >= 0 && c.Database.MinConns <= c.Database.MaxConns, "database.min_conns must be between 0 and max_conns")
+
+	check(c.Cache.Shards > 0, "cache.shards must be positive")
+	check(c.Cache.NegativeTTL >= 0, "cache.negative_ttl must not be negative")
+	check(c.Cache.SnapshotInterval > 0, "cache.snapshot_interval must be positive")
+	check(c.Cache.WarmupDays > 0, "cache.warmup_days must be positive")
+	check(c.Cache.WarmupLimit > 0, "cache.warmup_limit must be positive")
+	check(c.Cache.WarmupBatch > 0, "cache.warmup_batch must be positive")
+	check(c.Cache.AccessStatsFlushInterval > 0, "cache.access_stats_flush_interval must be positive")
+
+	check(!c.RateLimit.TrustProxy || c.RateLimit.TrustedProxies > 0, "rate_limit.trusted_proxies must be positive when trust_proxy is enabled")
+	check(!c.Auth.Disabled || (c.Auth.APIKeysFile == "" && c.Auth.JWKSFile == ""),
+		"auth.disabled cannot be combined with auth.api_keys_file or auth.jwks_file")
+
+	if c.RateLimit.Enabled {
+		_, err := ratelimit.ParseLimit(c.RateLimit.Default)
+		check(err == nil, "rate_limit.default: %v", err)
+		_, err = ratelimit.ParseLimit(c.RateLimit.AuthFailures)
+		check(err == nil, "rate_limit.auth_failures: %v", err)
+		for route, limit := range c.RateLimit.Routes {
+			_, err := ratelimit.ParseLimit(limit)
+			check(err == nil, "rate_limit.routes[%s]: %v", route, err)
+		}
+	}
+
+	check(c.Tracing.Exporter == "none" || c.Tracing.Exporter == "stdout" || c.Tracing.Exporter == "otlp",
+		"tracing.exporter must be none, stdout or otlp")
+
+	return errors.Join(errs...)
+}
+
+// DSN собирает строку подключения к PostgreSQL с экранированием логина и пароля
+func (d DatabaseConfig) DSN() string {
+	query := url.Values{}
+	query.Set("sslmode", d.SSLMode)
+	if d.SSLRootCert != "" {
+		query.Set("sslrootcert", d.SSLRootCert)
+	}
+	if d.SSLCert != "" {
+		query.Set("sslcert", d.SSLCert)
+	}
+	if d.SSLKey != "" {
+		query.Set("sslkey", d.SSLKey)
+	}
+	if d.ConnectTimeout > 0 {
+		query.Set("connect_timeout", strconv.Itoa(int(d.ConnectTimeout.Seconds())))
+	}
+
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(d.User, d.Password),
+		Host:     fmt.Sprintf("%s:%d", d.Host, d.Port),
+		Path:     "/" + d.Name,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// Redacted возвращает копию конфигурации со скрытыми секретами
+func (c Config) Redacted() Config {
+	for _, f := range fields(&c) {
+		if f.secret && f.value.String() != "" {
+			f.value.SetString("******")
+		}
+	}
+	return c
+}
+
+// String выводит конфигурацию в YAML без секретов
+func (c Config) String() string {
+	out, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return fmt.Sprintf("<invalid config: %v>", err)
+	}
+	return string(out)
+}
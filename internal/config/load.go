@@ -0,0 +1,195 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Переменная окружения с путем к файлу конфигурации, флаг -config ее перекрывает
+const fileEnv = "CONFIG_FILE"
+
+// field - лист структуры Config, который можно задать из окружения и флагом
+type field struct {
+	value reflect.Value
+	// Значение поля в Default()
+	def    reflect.Value
+	env    string
+	secret bool
+}
+
+func fields(c *Config) []field {
+	defaults := Default()
+	var out []field
+	var walk func(v, def reflect.Value)
+	walk = func(v, def reflect.Value) {
+		t := v.Type()
+		for i := range t.NumField() {
+			f := t.Field(i)
+			if f.Type.Kind() == reflect.Struct && f.Type != reflect.TypeFor[time.Duration]() {
+				walk(v.Field(i), def.Field(i))
+				continue
+			}
+			out = append(out, field{
+				value:  v.Field(i),
+				def:    def.Field(i),
+				env:    f.Tag.Get("env"),
+				secret: f.Tag.Get("secret") == "true",
+			})
+		}
+	}
+	walk(reflect.ValueOf(c).Elem(), reflect.ValueOf(&defaults).Elem())
+	return out
+}
+
+// flagName выводит имя флага из имени переменной: DB_MAX_CONNS -> db-max-conns
+func flagName(env string) string {
+	return strings.ToLower(strings.ReplaceAll(env, "_", "-"))
+}
+
+// setValue записывает в поле значение raw. Пустое значение возвращает поле к
+// значению по умолчанию, чтобы переменной окружения можно было отменить значение
+// из файла. Сброс к нулевому значению молча выключал бы, например, лимиты частоты
+func setValue(f field, raw string) error {
+	v := f.value
+	if raw == "" {
+		v.Set(f.def)
+		return nil
+	}
+	if v.Type() == reflect.TypeFor[time.Duration]() {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Map:
+		// Словарь строк задается как "ключ=значение;ключ=значение" и заменяется целиком
+		if v.Type().Key().Kind() != reflect.String || v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported config field type %s", v.Type())
+		}
+		m := reflect.MakeMap(v.Type())
+		for _, pair := range strings.Split(raw, ";") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("invalid entry %q: expected key=value", pair)
+			}
+			m.SetMapIndex(reflect.ValueOf(strings.TrimSpace(key)), reflect.ValueOf(strings.TrimSpace(value)))
+		}
+		v.Set(m)
+	default:
+		return fmt.Errorf("unsupported config field type %s", v.Type())
+	}
+	return nil
+}
+
+// Load собирает конфигурацию из источников по возрастанию приоритета:
+// значения по умолчанию, файл YAML/TOML (-config или CONFIG_FILE),
+// переменные окружения (включая .env), флаги командной строки.
+//
+// Флаги регистрируются в fs, поэтому подкоманды могут добавить к ним свои
+// до вызова Load. Возвращенная конфигурация уже проверена
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg := Default()
+	all := fields(&cfg)
+
+	configFile := fs.String("config", "", "path to YAML or TOML config file (env "+fileEnv+")")
+	overrides := make(map[string]string)
+	for _, f := range all {
+		name := flagName(f.env)
+		usage := "overrides " + f.env
+		if !f.secret {
+			usage += fmt.Sprintf(" (default %v)", f.value.Interface())
+		}
+		fs.Func(name, usage, func(raw string) error {
+			overrides[f.env] = raw
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	path := *configFile
+	if path == "" {
+		path = os.Getenv(fileEnv)
+	}
+	if path != "" {
+		if err := loadFile(&cfg, path); err != nil {
+			return nil, err
+		}
+	}
+
+	// Файл мог перезаписать поля, но адреса значений в all остались прежними
+	for _, f := range all {
+		raw, ok := os.LookupEnv(f.env)
+		if !ok {
+			continue
+		}
+		if err := setValue(f, raw); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", f.env, err)
+		}
+	}
+	for _, f := range all {
+		raw, ok := overrides[f.env]
+		if !ok {
+			continue
+		}
+		if err := setValue(f, raw); err != nil {
+			return nil, fmt.Errorf("invalid -%s: %w", flagName(f.env), err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return &cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		_, err = toml.Decode(string(data), cfg)
+	default:
+		return fmt.Errorf("unsupported config file extension %q, expected .yaml, .yml or .toml", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
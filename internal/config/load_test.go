@@ -0,0 +1,74 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestEmptyEnvResetsToDefault проверяет, что пустая переменная окружения
+// возвращает значение по умолчанию вместо значения из файла, а не нулевое
+func TestEmptyEnvResetsToDefault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	file := `database:
+  user: app
+  name: orders
+server:
+  grpc_port: 9191
+cache:
+  shards: 16
+  snapshot_path: /var/lib/orders/cache.snapshot
+rate_limit:
+  enabled: false
+  default: 5/s
+`
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	def := Default()
+
+	tests := []struct {
+		env  string
+		got  func(*Config) any
+		want any
+	}{
+		{"RATE_LIMIT_ENABLED", func(c *Config) any { return c.RateLimit.Enabled }, def.RateLimit.Enabled},
+		{"RATE_LIMIT_DEFAULT", func(c *Config) any { return c.RateLimit.Default }, def.RateLimit.Default},
+		{"SERVER_GRPC_PORT", func(c *Config) any { return c.Server.GRPCPort }, def.Server.GRPCPort},
+		{"CACHE_SHARDS", func(c *Config) any { return c.Cache.Shards }, def.Cache.Shards},
+		{"CACHE_SNAPSHOT_PATH", func(c *Config) any { return c.Cache.SnapshotPath }, def.Cache.SnapshotPath},
+	}
+	for _, tt := range tests {
+		t.Run(tt.env, func(t *testing.T) {
+			t.Setenv(fileEnv, path)
+			t.Setenv(tt.env, "")
+
+			cfg, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.got(cfg); got != tt.want {
+				t.Fatalf("%s= gives %v, want default %v", tt.env, got, tt.want)
+			}
+			if cfg.Database.User != "app" {
+				t.Fatalf("database.user = %q, want the value from the file", cfg.Database.User)
+			}
+		})
+	}
+}
+
+// TestEmptyFlagResetsToDefault - то же для флага с пустым значением
+func TestEmptyFlagResetsToDefault(t *testing.T) {
+	t.Setenv(fileEnv, "")
+	t.Setenv("DB_USER", "app")
+	t.Setenv("DB_NAME", "orders")
+	t.Setenv("SERVER_GRPC_PORT", "9191")
+	cfg, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"-server-grpc-port="})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.GRPCPort != Default().Server.GRPCPort {
+		t.Fatalf("grpc port = %d, want default %d", cfg.Server.GRPCPort, Default().Server.GRPCPort)
+	}
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"order-service/internal/models"
+)
+
+// RecordAccessStats прибавляет накопленные обращения к счетчикам заказов.
+// Заказы, которых уже нет в БД, пропускаются
+func (r *PostgresBase) RecordAccessStats(ctx context.Context, hits map[string]int64) error {
+	ctx, done := observe(ctx, "RecordAccessStats")
+	defer done()
+
+	if len(hits) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(hits))
+	counts := make([]int64, 0, len(hits))
+	for id, n := range hits {
+		ids = append(ids, id)
+		counts = append(counts, n)
+	}
+
+	_, err := r.pool.Exec(ctx, `
+        INSERT INTO order_access_stats (order_id, hits, last_access)
+        SELECT t.order_id, t.hits, CURRENT_TIMESTAMP
+        FROM unnest($1::varchar[], $2::bigint[]) AS t(order_id, hits)
+        WHERE EXISTS (SELECT 1 FROM orders o WHERE o.order_id = t.order_id)
+        ON CONFLICT (order_id) DO UPDATE SET
+            hits = order_access_stats.hits + EXCLUDED.hits,
+            last_access = EXCLUDED.last_access
+    `, ids, counts)
+
+	if err != nil {
+		return dbError(fmt.Errorf("failed to record access stats: %w", err))
+	}
+
+	return nil
+}
+
+// StreamTopAccessedOrders построчно читает limit самых запрашиваемых заказов
+func (r *PostgresBase) StreamTopAccessedOrders(ctx context.Context, limit int, fn func(*models.Order) error) error {
+	ctx, done := observe(ctx, "StreamTopAccessedOrders")
+	defer done()
+
+	rows, err := r.pool.Query(ctx, orderSelect+`
+        JOIN order_access_stats s ON s.order_id = o.order_id
+        ORDER BY s.hits DESC, o.order_id
+        LIMIT $1
+    `, limit)
+	if err != nil {
+		return dbError(fmt.Errorf("failed to query top accessed orders: %w", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		order, err := r.scanOrder(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(order); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return dbError(fmt.Errorf("failed to read top accessed orders: %w", err))
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"order-service/internal/auth"
+	"order-service/internal/logging"
+	"order-service/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Поля доставки, значения которых не попадают в журнал: иначе журнал хранил бы
+// персональные данные открыто и переживал бы их обезличивание
+var auditRedacted = map[string]bool{
+	"delivery.name":    true,
+	"delivery.phone":   true,
+	"delivery.email":   true,
+	"delivery.address": true,
+}
+
+const redactedValue = "[redacted]"
+
+// flattenOrder раскладывает заказ в плоский набор полей: вложенные объекты
+// через точку (delivery.city), список товаров целиком
+func flattenOrder(order *models.Order) (map[string]any, error) {
+	fields := map[string]any{}
+	if order == nil {
+		return fields, nil
+	}
+
+	data, err := json.Marshal(order)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	for key, value := range doc {
+		if nested, ok := value.(map[string]any); ok {
+			for nestedKey, nestedValue := range nested {
+				fields[key+"."+nestedKey] = nestedValue
+			}
+			continue
+		}
+		fields[key] = value
+	}
+	return fields, nil
+}
+
+// orderDiff возвращает изменившиеся поля между двумя состояниями заказа (nil - заказа нет)
+func orderDiff(before, after *models.Order) (map[string]models.FieldChange, error) {
+	b, err := flattenOrder(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := flattenOrder(after)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := map[string]models.FieldChange{}
+	for _, fields := range []map[string]any{b, a} {
+		for key := range fields {
+			if _, seen := diff[key]; seen || reflect.DeepEqual(b[key], a[key]) {
+				continue
+			}
+			change := models.FieldChange{Before: b[key], After: a[key]}
+			if auditRedacted[key] {
+				change = models.FieldChange{Before: redact(b[key]), After: redact(a[key])}
+			}
+			diff[key] = change
+		}
+	}
+	return diff, nil
+}
+
+func redact(value any) any {
+	if value == nil || value == "" {
+		return value
+	}
+	return redactedValue
+}
+
+// orderStateTx читает текущее состояние заказа внутри транзакции, nil - заказа нет
+func (r *PostgresBase) orderStateTx(ctx context.Context, tx pgx.Tx, orderID string) (*models.Order, error) {
+	rows, err := tx.Query(ctx, orderSelect+" WHERE o.order_id = $1", orderID)
+	if err != nil {
+		return nil, dbError(fmt.Errorf("failed to read order state: %w", err))
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, dbError(fmt.Errorf("failed to read order state: %w", err))
+		}
+		return nil, nil
+	}
+	return r.scanOrder(rows)
+}
+
+// recordAuditTx пишет в журнал изменение заказа от before к after. Сохранение
+// без изменений не записывается
+func recordAuditTx(ctx context.Context, tx pgx.Tx, operation, orderID string, clientID int64, before, after *models.Order) error {
+	diff, err := orderDiff(before, after)
+	if err != nil {
+		return fmt.Errorf("failed to build audit diff: %w", err)
+	}
+	if len(diff) == 0 {
+		return nil
+	}
+
+	var requestID *string
+	if id := logging.RequestID(ctx); id != "" {
+		requestID = &id
+	}
+
+	_, err = tx.Exec(ctx, `
+        INSERT INTO order_audit (order_id, client_id, operation, actor, request_id, diff)
+        VALUES ($1, $2, $3, $4, $5, $6)
+    `, orderID, clientID, operation, auth.Actor(ctx), requestID, diff)
+	if err != nil {
+		return dbError(fmt.Errorf("failed to write audit record: %w", err))
+	}
+	return nil
+}
+
+// GetOrderHistory возвращает журнал изменений заказа, новые записи первыми.
+// allowedClients ограничивает записи так же, как в SaveOrder
+func (r *PostgresBase) GetOrderHistory(ctx context.Context, orderID string, allowedClients []int64, limit int) ([]models.AuditEntry, error) {
+	ctx, done := observe(ctx, "GetOrderHistory")
+	defer done()
+
+	rows, err := r.pool.Query(ctx, `
+        SELECT audit_id, order_id, operation, actor, COALESCE(request_id, ''), changed_at, diff
+        FROM order_audit
+        WHERE order_id = $1
+          AND ($2::bigint[] IS NULL OR client_id = ANY($2))
+        ORDER BY audit_id DESC
+        LIMIT $3
+    `, orderID, allowedClients, limit)
+	if err != nil {
+		return nil, dbError(fmt.Errorf("failed to query order history: %w", err))
+	}
+
+	entries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.AuditEntry, error) {
+		var e models.AuditEntry
+		err := row.Scan(&e.AuditID, &e.OrderID, &e.Operation, &e.Actor, &e.RequestID, &e.ChangedAt, &e.Diff)
+		return e, err
+	})
+	if err != nil {
+		return nil, dbError(fmt.Errorf("failed to read order history: %w", err))
+	}
+	return entries, nil
+}
@@ -0,0 +1,168 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"order-service/internal/models"
+)
+
+// deliveryField - шифруемое поле доставки. Имя колонки входит в AAD шифртекста
+type deliveryField struct {
+	column string
+	value  func(d *models.Delivery) *string
+}
+
+var deliveryPII = []deliveryField{
+	{"name", func(d *models.Delivery) *string { return &d.Name }},
+	{"phone", func(d *models.Delivery) *string { return &d.Phone }},
+	{"email", func(d *models.Delivery) *string { return &d.Email }},
+	{"address", func(d *models.Delivery) *string { return &d.Address }},
+}
+
+func deliveryAAD(column, orderID string) string {
+	return "delivery." + column + ":" + orderID
+}
+
+// encryptDelivery возвращает копию доставки с зашифрованными персональными данными
+func (r *PostgresBase) encryptDelivery(orderID string, d models.Delivery) (models.Delivery, error) {
+	for _, f := range deliveryPII {
+		field := f.value(&d)
+		encrypted, err := r.keyring.Encrypt(*field, deliveryAAD(f.column, orderID))
+		if err != nil {
+			return d, fmt.Errorf("failed to encrypt delivery %s: %w", f.column, err)
+		}
+		*field = encrypted
+	}
+	return d, nil
+}
+
+func (r *PostgresBase) decryptDelivery(order *models.Order) error {
+	for _, f := range deliveryPII {
+		field := f.value(&order.Delivery)
+		plaintext, err := r.keyring.Decrypt(*field, deliveryAAD(f.column, order.OrderID))
+		if err != nil {
+			return fmt.Errorf("failed to decrypt delivery %s of order %s: %w", f.column, order.OrderID, err)
+		}
+		*field = plaintext
+	}
+	return nil
+}
+
+// emailHash - значение слепого индекса email, NULL без шифрования
+func (r *PostgresBase) emailHash(email string) *string {
+	hash := r.keyring.BlindIndex(email)
+	if hash == "" {
+		return nil
+	}
+	return &hash
+}
+
+// ReencryptDelivery перешифровывает персональные данные доставки активным ключом
+// и пересчитывает слепой индекс email. Открытые значения шифруются, уже
+// актуальные строки не трогаются. Обходит таблицу пачками по batchSize строк,
+// каждая пачка - отдельная транзакция. Возвращает число обновленных строк
+func (r *PostgresBase) ReencryptDelivery(ctx context.Context, batchSize int) (int, error) {
+	ctx, done := observe(ctx, "ReencryptDelivery")
+	defer done()
+
+	if r.keyring == nil {
+		return 0, errors.New("encryption keys are not configured")
+	}
+
+	updated := 0
+	after := ""
+	for {
+		n, last, err := r.reencryptBatch(ctx, after, batchSize)
+		updated += n
+		if err != nil {
+			return updated, err
+		}
+		if last == "" {
+			return updated, nil
+		}
+		after = last
+	}
+}
+
+// reencryptBatch обрабатывает до limit строк с order_id больше after и
+// возвращает число обновленных строк и последний order_id пачки ("" - строк не осталось)
+func (r *PostgresBase) reencryptBatch(ctx context.Context, after string, limit int) (int, string, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	type row struct {
+		order     models.Order
+		emailHash *string
+		stale     bool
+	}
+
+	rows, err := tx.Query(ctx, `
+        SELECT order_id, COALESCE(name, ''), COALESCE(phone, ''), COALESCE(email, ''),
+               COALESCE(address, ''), email_hash
+        FROM delivery
+        WHERE order_id > $1
+        ORDER BY order_id
+        LIMIT $2
+        FOR UPDATE
+    `, after, limit)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to query delivery: %w", err)
+	}
+
+	var batch []row
+	for rows.Next() {
+		var rw row
+		d := &rw.order.Delivery
+		if err := rows.Scan(&rw.order.OrderID, &d.Name, &d.Phone, &d.Email, &d.Address, &rw.emailHash); err != nil {
+			rows.Close()
+			return 0, "", fmt.Errorf("failed to scan delivery: %w", err)
+		}
+		for _, f := range deliveryPII {
+			rw.stale = rw.stale || r.keyring.NeedsRotation(*f.value(d))
+		}
+		batch = append(batch, rw)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, "", fmt.Errorf("failed to read delivery: %w", err)
+	}
+	if len(batch) == 0 {
+		return 0, "", nil
+	}
+
+	updated := 0
+	for _, rw := range batch {
+		if err := r.decryptDelivery(&rw.order); err != nil {
+			return 0, "", err
+		}
+		hash := r.emailHash(rw.order.Delivery.Email)
+		hashChanged := (hash == nil) != (rw.emailHash == nil) || (hash != nil && *hash != *rw.emailHash)
+		if !rw.stale && !hashChanged {
+			continue
+		}
+
+		d, err := r.encryptDelivery(rw.order.OrderID, rw.order.Delivery)
+		if err != nil {
+			return 0, "", err
+		}
+		_, err = tx.Exec(ctx, `
+            UPDATE delivery
+            SET name = $2, phone = $3, email = $4, address = $5, email_hash = $6
+            WHERE order_id = $1
+        `, rw.order.OrderID, d.Name, d.Phone, d.Email, d.Address, hash)
+		if err != nil {
+			return 0, "", fmt.Errorf("failed to update delivery of order %s: %w", rw.order.OrderID, err)
+		}
+		updated++
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return updated, batch[len(batch)-1].order.OrderID, nil
+}
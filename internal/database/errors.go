@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+
+	"order-service/internal/errs"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// dbError присваивает ошибке pgx категорию из errs. Текст исходной ошибки
+// остается в цепочке для логов, клиенту уходит только общее сообщение
+func dbError(err error) error {
+	var typed *errs.Error
+	if errors.As(err, &typed) {
+		return err
+	}
+	// Отмена запроса клиентом - не сбой БД, даже если сервер ответил query_canceled
+	if errors.Is(err, context.Canceled) {
+		return errs.Wrap(errs.Canceled, "request canceled", err)
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgError(pgErr, err)
+	}
+
+	var netErr net.Error
+	var connectErr *pgconn.ConnectError
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return errs.Wrap(errs.NotFound, "record not found", err)
+	case pgconn.Timeout(err), errors.Is(err, context.DeadlineExceeded):
+		return errs.Wrap(errs.Timeout, "database request timed out", err)
+	case errors.As(err, &connectErr), errors.As(err, &netErr), pgconn.SafeToRetry(err):
+		return errs.Wrap(errs.Unavailable, "database is unavailable", err)
+	}
+	return err
+}
+
+// Коды SQLSTATE: https://www.postgresql.org/docs/current/errcodes-appendix.html
+func pgError(pgErr *pgconn.PgError, err error) error {
+	switch pgErr.Code {
+	case "23505": // unique_violation
+		return errs.Wrap(errs.Conflict, "record already exists", err)
+	case "23503": // foreign_key_violation
+		return errs.Wrap(errs.Conflict, "referenced record does not exist or is still in use", err)
+	case "23502": // not_null_violation
+		return errs.Wrap(errs.Validation, withColumn("required field is missing", pgErr), err)
+	case "23514": // check_violation
+		return errs.Wrap(errs.Validation, withColumn("field value is not allowed", pgErr), err)
+	case "40001", "40P01": // serialization_failure, deadlock_detected
+		return errs.Wrap(errs.Conflict, "concurrent update, retry the request", err)
+	case "57014": // query_canceled, в том числе по statement_timeout
+		return errs.Wrap(errs.Timeout, "database request timed out", err)
+	case "57P01", "57P02", "57P03": // admin_shutdown, crash_shutdown, cannot_connect_now
+		return errs.Wrap(errs.Unavailable, "database is unavailable", err)
+	}
+
+	switch {
+	case strings.HasPrefix(pgErr.Code, "22"): // data_exception: длина, диапазон, формат
+		return errs.Wrap(errs.Validation, withColumn("invalid field value", pgErr), err)
+	case strings.HasPrefix(pgErr.Code, "08"), strings.HasPrefix(pgErr.Code, "53"): // connection_exception, insufficient_resources
+		return errs.Wrap(errs.Unavailable, "database is unavailable", err)
+	}
+	return err
+}
+
+func withColumn(msg string, pgErr *pgconn.PgError) string {
+	if pgErr.ColumnName == "" {
+		return msg
+	}
+	return msg + ": " + pgErr.TableName + "." + pgErr.ColumnName
+}
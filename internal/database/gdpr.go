@@ -0,0 +1,176 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"order-service/internal/logging"
+	"order-service/internal/models"
+	"order-service/internal/pii"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// subjectClause строит условие WHERE отбора заказов клиента по таблицам orders o и delivery d.
+// Email ищется по слепому индексу, а строки, записанные до включения
+// шифрования, - по открытому значению
+func (r *PostgresBase) subjectClause(subject models.DataSubject, allowedClients []int64) (string, []any) {
+	var conds []string
+	var args []any
+
+	if subject.ClientID != 0 {
+		args = append(args, subject.ClientID)
+		conds = append(conds, fmt.Sprintf("o.client_id = $%d", len(args)))
+	}
+	if email := strings.TrimSpace(subject.Email); email != "" {
+		args = append(args, r.emailHash(email), email)
+		conds = append(conds, fmt.Sprintf(
+			"(d.email_hash = $%d OR (d.email_hash IS NULL AND lower(d.email) = lower($%d)))",
+			len(args)-1, len(args),
+		))
+	}
+	if allowedClients != nil {
+		args = append(args, allowedClients)
+		conds = append(conds, fmt.Sprintf("o.client_id = ANY($%d)", len(args)))
+	}
+
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// recordSubjectRequest пишет запрос в журнал gdpr_requests и возвращает его ID
+func (r *PostgresBase) recordSubjectRequest(ctx context.Context, tx pgx.Tx, kind string, subject models.DataSubject, actor string, orderIDs []string) (int64, error) {
+	var clientID *int64
+	if subject.ClientID != 0 {
+		clientID = &subject.ClientID
+	}
+	var emailMasked *string
+	if subject.Email != "" {
+		masked := pii.MaskEmail(strings.TrimSpace(subject.Email))
+		emailMasked = &masked
+	}
+
+	var requestID int64
+	err := tx.QueryRow(ctx, `
+        INSERT INTO gdpr_requests (kind, client_id, email_hash, email_masked, actor, order_ids)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING request_id
+    `, kind, clientID, r.emailHash(subject.Email), emailMasked, actor, orderIDs).Scan(&requestID)
+	if err != nil {
+		return 0, dbError(fmt.Errorf("failed to record %s request: %w", kind, err))
+	}
+	return requestID, nil
+}
+
+// ExportSubjectData выбирает все заказы клиента вместе с доставкой и
+// фиксирует выгрузку в журнале. allowedClients ограничивает выборку так же, как в SaveOrder
+func (r *PostgresBase) ExportSubjectData(ctx context.Context, subject models.DataSubject, allowedClients []int64, actor string) (*models.SubjectDataExport, error) {
+	ctx, done := observe(ctx, "ExportSubjectData")
+	defer done()
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, dbError(fmt.Errorf("failed to begin transaction: %w", err))
+	}
+	defer tx.Rollback(ctx)
+
+	where, args := r.subjectClause(subject, allowedClients)
+	rows, err := tx.Query(ctx, orderSelect+where+" ORDER BY o.date_created, o.order_id", args...)
+	if err != nil {
+		return nil, dbError(fmt.Errorf("failed to query subject orders: %w", err))
+	}
+
+	result := &models.SubjectDataExport{Subject: subject, Orders: []*models.Order{}}
+	orderIDs := []string{}
+	for rows.Next() {
+		order, err := r.scanOrder(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		result.Orders = append(result.Orders, order)
+		orderIDs = append(orderIDs, order.OrderID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, dbError(fmt.Errorf("failed to read subject orders: %w", err))
+	}
+
+	if result.RequestID, err = r.recordSubjectRequest(ctx, tx, "export", subject, actor, orderIDs); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, dbError(fmt.Errorf("failed to commit transaction: %w", err))
+	}
+
+	result.GeneratedAt = time.Now().UTC()
+	return result, nil
+}
+
+// EraseSubjectData обезличивает доставку во всех заказах клиента и фиксирует
+// удаление в журнале. Платежи и состав заказов остаются для финансовой отчетности
+func (r *PostgresBase) EraseSubjectData(ctx context.Context, subject models.DataSubject, allowedClients []int64, actor string) (*models.SubjectErasure, error) {
+	ctx, done := observe(ctx, "EraseSubjectData")
+	defer done()
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, dbError(fmt.Errorf("failed to begin transaction: %w", err))
+	}
+	defer tx.Rollback(ctx)
+
+	where, args := r.subjectClause(subject, allowedClients)
+	rows, err := tx.Query(ctx, `
+        UPDATE delivery
+        SET name = '', phone = '', email = '', address = '', email_hash = NULL
+        WHERE order_id IN (
+            SELECT o.order_id
+            FROM orders o
+            JOIN delivery d ON d.order_id = o.order_id`+where+`
+        )
+        -- Уже обезличенные строки повторно не трогаем
+        AND (name <> '' OR phone <> '' OR email <> '' OR address <> '')
+        RETURNING order_id
+    `, args...)
+	if err != nil {
+		return nil, dbError(fmt.Errorf("failed to anonymize delivery: %w", err))
+	}
+	orderIDs, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, dbError(fmt.Errorf("failed to anonymize delivery: %w", err))
+	}
+	if orderIDs == nil {
+		orderIDs = []string{}
+	}
+
+	// Значения обезличенных полей в журнал не попадают, как и при обычном сохранении
+	erased := map[string]models.FieldChange{}
+	for field := range auditRedacted {
+		erased[field] = models.FieldChange{Before: redactedValue, After: ""}
+	}
+	var requestID *string
+	if id := logging.RequestID(ctx); id != "" {
+		requestID = &id
+	}
+	_, err = tx.Exec(ctx, `
+        INSERT INTO order_audit (order_id, client_id, operation, actor, request_id, diff)
+        SELECT order_id, client_id, $2, $3, $4, $5
+        FROM orders
+        WHERE order_id = ANY($1)
+    `, orderIDs, models.AuditErase, actor, requestID, erased)
+	if err != nil {
+		return nil, dbError(fmt.Errorf("failed to write audit records: %w", err))
+	}
+
+	result := &models.SubjectErasure{Subject: subject, OrderIDs: orderIDs}
+	if result.RequestID, err = r.recordSubjectRequest(ctx, tx, "erase", subject, actor, orderIDs); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, dbError(fmt.Errorf("failed to commit transaction: %w", err))
+	}
+
+	result.ErasedAt = time.Now().UTC()
+	return result, nil
+}
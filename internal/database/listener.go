@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Канал NOTIFY, в который триггеры пишут order_id измененного заказа
+const OrderChangesChannel = "order_changes"
+
+const (
+	listenMinBackoff = time.Second
+	listenMaxBackoff = 30 * time.Second
+)
+
+// ListenOrderChanges слушает уведомления об изменениях заказов до отмены ctx.
+// При обрыве соединения переподключается с экспоненциальной задержкой и после
+// восстановления вызывает onReconnect: уведомления за время разрыва потеряны
+func (r *PostgresBase) ListenOrderChanges(ctx context.Context, onChange func(orderID string), onReconnect func()) {
+	backoff := listenMinBackoff
+	connected := false
+
+	for {
+		err := r.listen(ctx, func() {
+			if connected {
+				onReconnect()
+			}
+			connected = true
+			backoff = listenMinBackoff
+		}, onChange)
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		slog.WarnContext(ctx, "order change listener disconnected", "error", err, "retry_in", backoff.String())
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, listenMaxBackoff)
+	}
+}
+
+func (r *PostgresBase) listen(ctx context.Context, onListen func(), onChange func(orderID string)) error {
+	poolConn, err := r.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+
+	// Забираем соединение из пула: в состоянии LISTEN возвращать его нельзя
+	conn := poolConn.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+OrderChangesChannel); err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	onListen()
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to wait for notification: %w", err)
+		}
+		onChange(notification.Payload)
+	}
+}
@@ -3,8 +3,11 @@ package database
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
 
+	"order-service/internal/errs"
 	"order-service/internal/models"
+	"order-service/internal/pii"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -12,67 +15,191 @@ import (
 
 type PostgresBase struct {
 	pool *pgxpool.Pool
+
+	// Схема создана и миграции InitDB применены
+	initialized atomic.Bool
+
+	// Шифрование персональных данных доставки, nil - хранить открыто
+	keyring *pii.Keyring
 }
 
 func NewPostgresBase(pool *pgxpool.Pool) *PostgresBase {
 	return &PostgresBase{pool: pool}
 }
 
-func (r *PostgresBase) SaveOrder(ctx context.Context, order *models.Order) error {
+// SetKeyring включает шифрование персональных данных доставки. Вызывается до
+// начала работы с БД. Уже записанные открытые значения читаются как есть,
+// перешифровать их можно через ReencryptDelivery
+func (r *PostgresBase) SetKeyring(keyring *pii.Keyring) {
+	r.keyring = keyring
+}
+
+// ErrForeignOrder - заказ с таким order_id уже принадлежит клиенту вне разрешенного списка
+var ErrForeignOrder = errs.New(errs.Forbidden, "order belongs to another client")
+
+// ErrOrderDeleted - заказ удалили, пока его сохраняли
+var ErrOrderDeleted = errs.New(errs.Conflict, "order was deleted concurrently, retry the request")
+
+// SaveOrder сохраняет заказ. Если allowedClients не nil, существующий заказ
+// перезаписывается, только если его текущий client_id входит в этот список
+func (r *PostgresBase) SaveOrder(ctx context.Context, order *models.Order, allowedClients []int64) error {
+	ctx, done := observe(ctx, "SaveOrder")
+	defer done()
+
 	// Начинаем транзакцию
 	tx, err := r.pool.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return dbError(fmt.Errorf("failed to begin transaction: %w", err))
 	}
 	defer tx.Rollback(ctx)
 
-	// 1. Сохраняем основной заказ
-	_, err = tx.Exec(ctx, `
+	if err := r.saveOrderTx(ctx, tx, order, allowedClients); err != nil {
+		return err
+	}
+
+	// Коммитим транзакцию
+	if err := tx.Commit(ctx); err != nil {
+		return dbError(fmt.Errorf("failed to commit transaction: %w", err))
+	}
+
+	return nil
+}
+
+// BatchError указывает, на каком заказе оборвалась пакетная транзакция
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("order #%d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// SaveOrders сохраняет все заказы в одной транзакции: либо все, либо ни одного.
+// allowedClients работает так же, как в SaveOrder
+func (r *PostgresBase) SaveOrders(ctx context.Context, orders []*models.Order, allowedClients []int64) error {
+	ctx, done := observe(ctx, "SaveOrders")
+	defer done()
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return dbError(fmt.Errorf("failed to begin transaction: %w", err))
+	}
+	defer tx.Rollback(ctx)
+
+	for i, order := range orders {
+		if err := r.saveOrderTx(ctx, tx, order, allowedClients); err != nil {
+			return &BatchError{Index: i, Err: err}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return dbError(fmt.Errorf("failed to commit transaction: %w", err))
+	}
+
+	return nil
+}
+
+func (r *PostgresBase) saveOrderTx(ctx context.Context, tx pgx.Tx, order *models.Order, allowedClients []int64) error {
+	// 1. Сохраняем основной заказ. Сначала пробуем вставить строку: если
+	// параллельно создается тот же заказ, вставка дождется его фиксации и
+	// попадет в конфликт, поэтому создание журналируется ровно один раз
+	tag, err := tx.Exec(ctx, `
         INSERT INTO orders (order_id, client_id, locale, date_created)
         VALUES ($1, $2, $3, $4)
-        ON CONFLICT (order_id) DO UPDATE SET
-            client_id = EXCLUDED.client_id,
-            locale = EXCLUDED.locale,
-            date_created = EXCLUDED.date_created
+        ON CONFLICT (order_id) DO NOTHING
     `, order.OrderID, order.ClientID, order.Locale, order.DateCreated)
-
 	if err != nil {
-		return fmt.Errorf("failed to save order: %w", err)
+		return dbError(fmt.Errorf("failed to save order: %w", err))
 	}
+	created := tag.RowsAffected() == 1
 
-	// 2. Сохраняем доставку
+	var before *models.Order
+	if !created {
+		// Состояние до изменения для журнала. Блокировка не дает параллельному
+		// сохранению того же заказа вклиниться между чтением и записью
+		_, err := tx.Exec(ctx, `SELECT 1 FROM orders WHERE order_id = $1 FOR UPDATE`, order.OrderID)
+		if err != nil {
+			return dbError(fmt.Errorf("failed to lock order: %w", err))
+		}
+		before, err = r.orderStateTx(ctx, tx, order.OrderID)
+		if err != nil {
+			return err
+		}
+		if before == nil {
+			return ErrOrderDeleted
+		}
+
+		// Чужой заказ не обновляется, и строк затронуто не будет
+		tag, err = tx.Exec(ctx, `
+            UPDATE orders SET
+                client_id = $2,
+                locale = $3,
+                date_created = $4
+            WHERE order_id = $1 AND ($5::bigint[] IS NULL OR client_id = ANY($5))
+        `, order.OrderID, order.ClientID, order.Locale, order.DateCreated, allowedClients)
+		if err != nil {
+			return dbError(fmt.Errorf("failed to save order: %w", err))
+		}
+		if tag.RowsAffected() == 0 {
+			return ErrForeignOrder
+		}
+	}
+
+	// 2. Сохраняем доставку, персональные данные - в зашифрованном виде
+	delivery, err := r.encryptDelivery(order.OrderID, order.Delivery)
+	if err != nil {
+		return err
+	}
 	_, err = tx.Exec(ctx, `
-        INSERT INTO delivery (order_id, name, phone, email, type, city, address)
-        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        INSERT INTO delivery (order_id, name, phone, email, type, city, address, email_hash)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
         ON CONFLICT (order_id) DO UPDATE SET
             name = EXCLUDED.name,
             phone = EXCLUDED.phone,
             email = EXCLUDED.email,
             type = EXCLUDED.type,
             city = EXCLUDED.city,
-            address = EXCLUDED.address
-    `, order.OrderID, order.Delivery.Name, order.Delivery.Phone,
-		order.Delivery.Email, order.Delivery.Type,
-		order.Delivery.City, order.Delivery.Address)
+            address = EXCLUDED.address,
+            email_hash = EXCLUDED.email_hash
+    `, order.OrderID, delivery.Name, delivery.Phone,
+		delivery.Email, delivery.Type,
+		delivery.City, delivery.Address, r.emailHash(order.Delivery.Email))
 
 	if err != nil {
-		return fmt.Errorf("failed to save delivery: %w", err)
+		return dbError(fmt.Errorf("failed to save delivery: %w", err))
 	}
 
-	// 3. Сохраняем платеж
+	// 3. Сохраняем платеж, у заказа он один
 	_, err = tx.Exec(ctx, `
         INSERT INTO payment (order_id, transaction_id, currency, provider, amount, date_pay, bank)
         VALUES ($1, $2, $3, $4, $5, $6, $7)
-        ON CONFLICT DO NOTHING
+        ON CONFLICT (order_id) DO UPDATE SET
+            transaction_id = EXCLUDED.transaction_id,
+            currency = EXCLUDED.currency,
+            provider = EXCLUDED.provider,
+            amount = EXCLUDED.amount,
+            date_pay = EXCLUDED.date_pay,
+            bank = EXCLUDED.bank
     `, order.OrderID, order.Payment.Transaction, order.Payment.Currency,
 		order.Payment.Provider, order.Payment.Amount, order.Payment.DatePay,
 		order.Payment.Bank)
 
 	if err != nil {
-		return fmt.Errorf("failed to save payment: %w", err)
+		return dbError(fmt.Errorf("failed to save payment: %w", err))
 	}
 
-	// 4. Сохраняем товары
+	// 4. Сохраняем товары. Состав заказа заменяется целиком: у строк items нет
+	// естественного ключа, и повторная вставка дублировала бы товары
+	if !created {
+		if _, err := tx.Exec(ctx, `DELETE FROM items WHERE order_id = $1`, order.OrderID); err != nil {
+			return dbError(fmt.Errorf("failed to replace order items: %w", err))
+		}
+	}
 	for _, item := range order.Items {
 		// Сохраняем товар в таблицу item
 		_, err = tx.Exec(ctx, `
@@ -86,30 +213,36 @@ func (r *PostgresBase) SaveOrder(ctx context.Context, order *models.Order) error
         `, item.ProductID, item.Name, item.Brand, item.Price, item.Size)
 
 		if err != nil {
-			return fmt.Errorf("failed to save item: %w", err)
+			return dbError(fmt.Errorf("failed to save item: %w", err))
 		}
 
 		// Сохраняем связь заказа с товаром
 		_, err = tx.Exec(ctx, `
             INSERT INTO items (order_id, product_id, quantity)
             VALUES ($1, $2, $3)
-            ON CONFLICT DO NOTHING
         `, order.OrderID, item.ProductID, item.Quantity)
 
 		if err != nil {
-			return fmt.Errorf("failed to save order-item link: %w", err)
+			return dbError(fmt.Errorf("failed to save order-item link: %w", err))
 		}
 	}
 
-	// Коммитим транзакцию
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	// 5. Пишем в журнал состояние после записи, прочитанное из БД
+	after, err := r.orderStateTx(ctx, tx, order.OrderID)
+	if err != nil {
+		return err
 	}
-
-	return nil
+	operation := models.AuditUpdate
+	if created {
+		operation = models.AuditCreate
+	}
+	return recordAuditTx(ctx, tx, operation, order.OrderID, order.ClientID, before, after)
 }
 
 func (r *PostgresBase) GetOrder(ctx context.Context, orderID string) (*models.Order, error) {
+	ctx, done := observe(ctx, "GetOrder")
+	defer done()
+
 	// Получаем основной заказ
 	var order models.Order
 	err := r.pool.QueryRow(ctx, `
@@ -124,7 +257,7 @@ func (r *PostgresBase) GetOrder(ctx context.Context, orderID string) (*models.Or
 		if err == pgx.ErrNoRows {
 			return nil, nil
 		}
-		return nil, fmt.Errorf("failed to get order: %w", err)
+		return nil, dbError(fmt.Errorf("failed to get order: %w", err))
 	}
 
 	// Получаем доставку
@@ -138,7 +271,10 @@ func (r *PostgresBase) GetOrder(ctx context.Context, orderID string) (*models.Or
 	)
 
 	if err != nil && err != pgx.ErrNoRows {
-		return nil, fmt.Errorf("failed to get delivery: %w", err)
+		return nil, dbError(fmt.Errorf("failed to get delivery: %w", err))
+	}
+	if err := r.decryptDelivery(&order); err != nil {
+		return nil, err
 	}
 
 	// Получаем платеж
@@ -152,7 +288,7 @@ func (r *PostgresBase) GetOrder(ctx context.Context, orderID string) (*models.Or
 	)
 
 	if err != nil && err != pgx.ErrNoRows {
-		return nil, fmt.Errorf("failed to get payment: %w", err)
+		return nil, dbError(fmt.Errorf("failed to get payment: %w", err))
 	}
 
 	// Получаем товары
@@ -164,7 +300,7 @@ func (r *PostgresBase) GetOrder(ctx context.Context, orderID string) (*models.Or
     `, orderID)
 
 	if err != nil && err != pgx.ErrNoRows {
-		return nil, fmt.Errorf("failed to get items: %w", err)
+		return nil, dbError(fmt.Errorf("failed to get items: %w", err))
 	}
 	defer rows.Close()
 
@@ -176,7 +312,7 @@ func (r *PostgresBase) GetOrder(ctx context.Context, orderID string) (*models.Or
 			&item.Size, &item.Quantity,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan item: %w", err)
+			return nil, dbError(fmt.Errorf("failed to scan item: %w", err))
 		}
 		items = append(items, item)
 	}
@@ -185,92 +321,6 @@ func (r *PostgresBase) GetOrder(ctx context.Context, orderID string) (*models.Or
 	return &order, nil
 }
 
-func (r *PostgresBase) GetAllOrders(ctx context.Context) ([]models.Order, error) {
-	// Получаем все заказы (ограничиваем для производительности)
-	rows, err := r.pool.Query(ctx, `
-        SELECT order_id, client_id, locale, date_created
-        FROM orders 
-        ORDER BY date_created DESC 
-        LIMIT 100
-    `)
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to get orders: %w", err)
-	}
-	defer rows.Close()
-
-	var orders []models.Order
-	for rows.Next() {
-		var order models.Order
-		err := rows.Scan(
-			&order.OrderID, &order.ClientID, &order.Locale, &order.DateCreated,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan order: %w", err)
-		}
-
-		// Получаем доставку для каждого заказа
-		err = r.pool.QueryRow(ctx, `
-            SELECT name, phone, email, type, city, address
-            FROM delivery
-            WHERE order_id = $1
-        `, order.OrderID).Scan(
-			&order.Delivery.Name, &order.Delivery.Phone, &order.Delivery.Email,
-			&order.Delivery.Type, &order.Delivery.City, &order.Delivery.Address,
-		)
-
-		if err != nil && err != pgx.ErrNoRows {
-			return nil, fmt.Errorf("failed to get delivery for order %s: %w", order.OrderID, err)
-		}
-
-		// Получаем платеж для каждого заказа
-		err = r.pool.QueryRow(ctx, `
-            SELECT transaction_id, currency, provider, amount, date_pay, bank
-            FROM payment
-            WHERE order_id = $1
-        `, order.OrderID).Scan(
-			&order.Payment.Transaction, &order.Payment.Currency, &order.Payment.Provider,
-			&order.Payment.Amount, &order.Payment.DatePay, &order.Payment.Bank,
-		)
-
-		if err != nil && err != pgx.ErrNoRows {
-			return nil, fmt.Errorf("failed to get payment for order %s: %w", order.OrderID, err)
-		}
-
-		// Получаем товары для каждого заказа
-		itemRows, err := r.pool.Query(ctx, `
-            SELECT i.product_id, i.name, i.brand, i.price, i.size, it.quantity
-            FROM items it
-            JOIN item i ON it.product_id = i.product_id
-            WHERE it.order_id = $1
-        `, order.OrderID)
-
-		if err != nil && err != pgx.ErrNoRows {
-			return nil, fmt.Errorf("failed to get items for order %s: %w", order.OrderID, err)
-		}
-
-		var items []models.Product
-		for itemRows.Next() {
-			var item models.Product
-			err := itemRows.Scan(
-				&item.ProductID, &item.Name, &item.Brand, &item.Price,
-				&item.Size, &item.Quantity,
-			)
-			if err != nil {
-				itemRows.Close()
-				return nil, fmt.Errorf("failed to scan item: %w", err)
-			}
-			items = append(items, item)
-		}
-		itemRows.Close()
-		order.Items = items
-
-		orders = append(orders, order)
-	}
-
-	return orders, nil
-}
-
 func (r *PostgresBase) InitDB(ctx context.Context) error {
 	// Ваши таблицы уже созданы, проверяем их существование
 	// (или просто пропускаем инициализацию, если таблицы уже есть)
@@ -373,5 +423,203 @@ func (r *PostgresBase) InitDB(ctx context.Context) error {
 		}
 	}
 
+	// Таблицы, появившиеся после первой версии схемы
+	_, err = r.pool.Exec(ctx, `
+        CREATE TABLE IF NOT EXISTS order_access_stats (
+            order_id VARCHAR(50) PRIMARY KEY REFERENCES orders(order_id) ON DELETE CASCADE,
+            hits BIGINT NOT NULL DEFAULT 0,
+            last_access TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+        );
+        CREATE INDEX IF NOT EXISTS idx_order_access_stats_hits ON order_access_stats(hits DESC);
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to create order_access_stats table: %w", err)
+	}
+
+	// Шифртекст персональных данных длиннее исходных значений, а поиск
+	// по email идет через слепой индекс
+	_, err = r.pool.Exec(ctx, `
+        DO $$
+        BEGIN
+            IF EXISTS (
+                SELECT 1 FROM information_schema.columns
+                WHERE table_name = 'delivery'
+                  AND column_name IN ('name', 'phone', 'email', 'address')
+                  AND data_type <> 'text'
+            ) THEN
+                ALTER TABLE delivery
+                    ALTER COLUMN name TYPE TEXT,
+                    ALTER COLUMN phone TYPE TEXT,
+                    ALTER COLUMN email TYPE TEXT,
+                    ALTER COLUMN address TYPE TEXT;
+            END IF;
+        END;
+        $$;
+        ALTER TABLE delivery ADD COLUMN IF NOT EXISTS email_hash TEXT;
+        CREATE INDEX IF NOT EXISTS idx_delivery_email_hash ON delivery(email_hash);
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to migrate delivery table for encryption: %w", err)
+	}
+
+	// Платеж у заказа один, чтобы сохранение могло его обновлять. Из прежних
+	// повторов остается первый: его и отдавало чтение заказа
+	_, err = r.pool.Exec(ctx, `
+        DO $$
+        BEGIN
+            IF NOT EXISTS (
+                SELECT 1 FROM pg_indexes
+                WHERE schemaname = 'public' AND indexname = 'payment_order_id_key'
+            ) THEN
+                DELETE FROM payment p
+                USING payment kept
+                WHERE p.order_id = kept.order_id AND p.payment_id > kept.payment_id;
+                CREATE UNIQUE INDEX payment_order_id_key ON payment(order_id);
+            END IF;
+        END;
+        $$;
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to migrate payment table: %w", err)
+	}
+
+	// Журнал запросов на выгрузку и удаление персональных данных. Email хранится
+	// только в виде слепого индекса и маски
+	_, err = r.pool.Exec(ctx, `
+        CREATE TABLE IF NOT EXISTS gdpr_requests (
+            request_id BIGSERIAL PRIMARY KEY,
+            kind VARCHAR(10) NOT NULL CHECK (kind IN ('export', 'erase')),
+            client_id BIGINT,
+            email_hash TEXT,
+            email_masked TEXT,
+            actor TEXT NOT NULL,
+            order_ids TEXT[] NOT NULL,
+            created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+        );
+        CREATE INDEX IF NOT EXISTS idx_gdpr_requests_client_id ON gdpr_requests(client_id);
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to create gdpr_requests table: %w", err)
+	}
+
+	// Журнал изменений заказов. Записи только добавляются, удаление заказа
+	// фиксируется триггером, в том числе при правке БД в обход сервиса
+	_, err = r.pool.Exec(ctx, `
+        CREATE TABLE IF NOT EXISTS order_audit (
+            audit_id BIGSERIAL PRIMARY KEY,
+            order_id VARCHAR(50) NOT NULL,
+            client_id BIGINT NOT NULL,
+            operation VARCHAR(10) NOT NULL CHECK (operation IN ('create', 'update', 'delete', 'erase')),
+            actor TEXT NOT NULL,
+            request_id TEXT,
+            changed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+            diff JSONB NOT NULL
+        );
+        CREATE INDEX IF NOT EXISTS idx_order_audit_order_id ON order_audit(order_id, audit_id DESC);
+
+        CREATE OR REPLACE FUNCTION order_audit_append_only() RETURNS trigger AS $$
+        BEGIN
+            RAISE EXCEPTION 'order_audit is append-only';
+        END;
+        $$ LANGUAGE plpgsql;
+
+        CREATE OR REPLACE TRIGGER order_audit_no_update
+            BEFORE UPDATE OR DELETE ON order_audit
+            FOR EACH ROW EXECUTE FUNCTION order_audit_append_only();
+        CREATE OR REPLACE TRIGGER order_audit_no_truncate
+            BEFORE TRUNCATE ON order_audit
+            FOR EACH STATEMENT EXECUTE FUNCTION order_audit_append_only();
+
+        CREATE OR REPLACE FUNCTION audit_order_delete() RETURNS trigger AS $$
+        BEGIN
+            INSERT INTO order_audit (order_id, client_id, operation, actor, diff)
+            VALUES (OLD.order_id, OLD.client_id, 'delete', 'db:' || session_user, jsonb_build_object(
+                'order_id', jsonb_build_object('before', OLD.order_id, 'after', NULL),
+                'client_id', jsonb_build_object('before', OLD.client_id, 'after', NULL),
+                'locale', jsonb_build_object('before', OLD.locale, 'after', NULL),
+                'date_created', jsonb_build_object('before', OLD.date_created, 'after', NULL)
+            ));
+            RETURN NULL;
+        END;
+        $$ LANGUAGE plpgsql;
+
+        CREATE OR REPLACE TRIGGER orders_audit_delete
+            AFTER DELETE ON orders
+            FOR EACH ROW EXECUTE FUNCTION audit_order_delete();
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to create order_audit table: %w", err)
+	}
+
+	// Уведомления об изменениях заказов для сброса кэша на других репликах
+	_, err = r.pool.Exec(ctx, `
+        CREATE OR REPLACE FUNCTION notify_order_change() RETURNS trigger AS $$
+        BEGIN
+            IF TG_OP = 'DELETE' THEN
+                PERFORM pg_notify('`+OrderChangesChannel+`', OLD.order_id);
+            ELSE
+                PERFORM pg_notify('`+OrderChangesChannel+`', NEW.order_id);
+            END IF;
+            RETURN NULL;
+        END;
+        $$ LANGUAGE plpgsql;
+
+        CREATE OR REPLACE FUNCTION notify_product_change() RETURNS trigger AS $$
+        BEGIN
+            PERFORM pg_notify('`+OrderChangesChannel+`', it.order_id)
+            FROM items it
+            WHERE it.product_id = NEW.product_id;
+            RETURN NULL;
+        END;
+        $$ LANGUAGE plpgsql;
+
+        CREATE OR REPLACE TRIGGER orders_notify_change
+            AFTER INSERT OR UPDATE OR DELETE ON orders
+            FOR EACH ROW EXECUTE FUNCTION notify_order_change();
+        CREATE OR REPLACE TRIGGER delivery_notify_change
+            AFTER INSERT OR UPDATE OR DELETE ON delivery
+            FOR EACH ROW EXECUTE FUNCTION notify_order_change();
+        CREATE OR REPLACE TRIGGER payment_notify_change
+            AFTER INSERT OR UPDATE OR DELETE ON payment
+            FOR EACH ROW EXECUTE FUNCTION notify_order_change();
+        CREATE OR REPLACE TRIGGER items_notify_change
+            AFTER INSERT OR UPDATE OR DELETE ON items
+            FOR EACH ROW EXECUTE FUNCTION notify_order_change();
+        CREATE OR REPLACE TRIGGER item_notify_change
+            AFTER UPDATE ON item
+            FOR EACH ROW WHEN (OLD IS DISTINCT FROM NEW)
+            EXECUTE FUNCTION notify_product_change();
+    `)
+	if err != nil {
+		return fmt.Errorf("failed to create change notification triggers: %w", err)
+	}
+
+	r.initialized.Store(true)
 	return nil
 }
+
+// Initialized сообщает, что InitDB успешно отработал
+func (r *PostgresBase) Initialized() bool {
+	return r.initialized.Load()
+}
+
+func (r *PostgresBase) Ping(ctx context.Context) error {
+	return r.pool.Ping(ctx)
+}
+
+type PoolStats struct {
+	TotalConns    int32 `json:"total_conns"`
+	IdleConns     int32 `json:"idle_conns"`
+	AcquiredConns int32 `json:"acquired_conns"`
+	MaxConns      int32 `json:"max_conns"`
+}
+
+func (r *PostgresBase) PoolStats() PoolStats {
+	stat := r.pool.Stat()
+	return PoolStats{
+		TotalConns:    stat.TotalConns(),
+		IdleConns:     stat.IdleConns(),
+		AcquiredConns: stat.AcquiredConns(),
+		MaxConns:      stat.MaxConns(),
+	}
+}
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"order-service/internal/models"
+)
+
+// testDB подключается к PostgreSQL из TEST_DATABASE_URL. Без переменной тест
+// пропускается: схема создается в указанной БД, поэтому нужна отдельная
+func testDB(t *testing.T) *PostgresBase {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	db := NewPostgresBase(pool)
+	if err := db.InitDB(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSaveOrderReplacesItemsAndPayment(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	orderID := fmt.Sprintf("test-save-%d", time.Now().UnixNano())
+	t.Cleanup(func() {
+		db.pool.Exec(ctx, `DELETE FROM orders WHERE order_id = $1`, orderID)
+	})
+
+	order := &models.Order{
+		OrderID:     orderID,
+		ClientID:    1,
+		DateCreated: time.Now().UTC().Truncate(time.Microsecond),
+		Payment:     models.Payment{Transaction: "tx-1", Currency: "RUB", Amount: 100},
+		Items: []models.Product{
+			{ProductID: 900001, Name: "first", Quantity: 1},
+			{ProductID: 900002, Name: "second", Quantity: 2},
+		},
+	}
+	if err := db.SaveOrder(ctx, order, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Повторное сохранение с другим составом и платежом
+	order.Payment = models.Payment{Transaction: "tx-2", Currency: "RUB", Amount: 250}
+	order.Items = []models.Product{{ProductID: 900003, Name: "third", Quantity: 5}}
+	if err := db.SaveOrder(ctx, order, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var items, payments int
+	err := db.pool.QueryRow(ctx, `
+        SELECT (SELECT count(*) FROM items WHERE order_id = $1),
+               (SELECT count(*) FROM payment WHERE order_id = $1)
+    `, orderID).Scan(&items, &payments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items != 1 || payments != 1 {
+		t.Fatalf("after update: %d items, %d payments, want 1 and 1", items, payments)
+	}
+
+	got, err := db.GetOrder(ctx, orderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Payment.Transaction != "tx-2" || got.Payment.Amount != 250 {
+		t.Errorf("payment = %+v, want the updated one", got.Payment)
+	}
+	if len(got.Items) != 1 || got.Items[0].ProductID != 900003 || got.Items[0].Quantity != 5 {
+		t.Errorf("items = %+v, want only the updated item", got.Items)
+	}
+}
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"order-service/internal/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Заказ целиком одной строкой: доставка и платеж через JOIN, товары агрегируются в JSON
+const orderSelect = `
+        SELECT o.order_id, o.client_id, o.locale, o.date_created,
+               COALESCE(d.name, ''), COALESCE(d.phone, ''), COALESCE(d.email, ''),
+               COALESCE(d.type, ''), COALESCE(d.city, ''), COALESCE(d.address, ''),
+               COALESCE(p.transaction_id, ''), COALESCE(p.currency, ''), COALESCE(p.provider, ''),
+               COALESCE(p.amount, 0), COALESCE(p.date_pay, 0), COALESCE(p.bank, ''),
+               COALESCE((
+                   SELECT json_agg(json_build_object(
+                       'product_id', i.product_id,
+                       'name', i.name,
+                       'brand', i.brand,
+                       'price', i.price,
+                       'size', i.size,
+                       'quantity', it.quantity
+                   ) ORDER BY it.items_id)
+                   FROM items it
+                   JOIN item i ON it.product_id = i.product_id
+                   WHERE it.order_id = o.order_id
+               ), '[]'::json)
+        FROM orders o
+        LEFT JOIN delivery d ON d.order_id = o.order_id
+        LEFT JOIN payment p ON p.order_id = o.order_id
+`
+
+func (r *PostgresBase) scanOrder(rows pgx.Rows) (*models.Order, error) {
+	var order models.Order
+	var items []byte
+	err := rows.Scan(
+		&order.OrderID, &order.ClientID, &order.Locale, &order.DateCreated,
+		&order.Delivery.Name, &order.Delivery.Phone, &order.Delivery.Email,
+		&order.Delivery.Type, &order.Delivery.City, &order.Delivery.Address,
+		&order.Payment.Transaction, &order.Payment.Currency, &order.Payment.Provider,
+		&order.Payment.Amount, &order.Payment.DatePay, &order.Payment.Bank,
+		&items,
+	)
+	if err != nil {
+		return nil, dbError(fmt.Errorf("failed to scan order: %w", err))
+	}
+
+	if err := json.Unmarshal(items, &order.Items); err != nil {
+		return nil, fmt.Errorf("failed to decode items for order %s: %w", order.OrderID, err)
+	}
+	if len(order.Items) == 0 {
+		order.Items = nil
+	}
+	if err := r.decryptDelivery(&order); err != nil {
+		return nil, err
+	}
+
+	return &order, nil
+}
+
+func filterClause(filter models.OrderFilter) (string, []any) {
+	var conds []string
+	var args []any
+
+	if filter.ClientID != 0 {
+		args = append(args, filter.ClientID)
+		conds = append(conds, fmt.Sprintf("o.client_id = $%d", len(args)))
+	}
+	if filter.ClientIDs != nil {
+		args = append(args, filter.ClientIDs)
+		conds = append(conds, fmt.Sprintf("o.client_id = ANY($%d)", len(args)))
+	}
+	if !filter.CreatedFrom.IsZero() {
+		args = append(args, filter.CreatedFrom)
+		conds = append(conds, fmt.Sprintf("o.date_created >= $%d", len(args)))
+	}
+	if !filter.CreatedTo.IsZero() {
+		args = append(args, filter.CreatedTo)
+		conds = append(conds, fmt.Sprintf("o.date_created < $%d", len(args)))
+	}
+	if filter.After != nil {
+		args = append(args, filter.After.DateCreated, filter.After.OrderID)
+		conds = append(conds, fmt.Sprintf("(o.date_created < $%d OR (o.date_created = $%d AND o.order_id > $%d))",
+			len(args)-1, len(args)-1, len(args)))
+	}
+
+	query := ""
+	if len(conds) > 0 {
+		query = " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY o.date_created DESC, o.order_id"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	return query, args
+}
+
+// StreamOrders построчно читает заказы из БД и передает их в fn,
+// не загружая всю выборку в память
+func (r *PostgresBase) StreamOrders(ctx context.Context, filter models.OrderFilter, fn func(*models.Order) error) error {
+	ctx, done := observe(ctx, "StreamOrders")
+	defer done()
+
+	where, args := filterClause(filter)
+
+	rows, err := r.pool.Query(ctx, orderSelect+where, args...)
+	if err != nil {
+		return dbError(fmt.Errorf("failed to query orders: %w", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		order, err := r.scanOrder(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(order); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return dbError(fmt.Errorf("failed to read orders: %w", err))
+	}
+
+	return nil
+}
+
+// GetOrders получает несколько заказов одним запросом. Отсутствующие ID просто не попадают в результат
+func (r *PostgresBase) GetOrders(ctx context.Context, orderIDs []string) ([]*models.Order, error) {
+	ctx, done := observe(ctx, "GetOrders")
+	defer done()
+
+	rows, err := r.pool.Query(ctx, orderSelect+" WHERE o.order_id = ANY($1)", orderIDs)
+	if err != nil {
+		return nil, dbError(fmt.Errorf("failed to get orders: %w", err))
+	}
+	defer rows.Close()
+
+	orders := make([]*models.Order, 0, len(orderIDs))
+	for rows.Next() {
+		order, err := r.scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, dbError(fmt.Errorf("failed to read orders: %w", err))
+	}
+
+	return orders, nil
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"order-service/internal/logging"
+	"order-service/internal/metrics"
+	"order-service/internal/telemetry"
+)
+
+var tracer = telemetry.Tracer("database")
+
+// observe открывает span метода PostgresBase и по завершении пишет его длительность в метрики
+func observe(ctx context.Context, method string) (context.Context, func()) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, "PostgresBase."+method)
+	return ctx, func() {
+		span.End()
+		metrics.ObserveDBQuery(method, start)
+	}
+}
+
+// QueryTracer создает span на каждый SQL-запрос. Подключается через pgx.ConnConfig.Tracer
+type QueryTracer struct{}
+
+func (QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	attrs := []attribute.KeyValue{
+		attribute.String("db.system.name", "postgresql"),
+		attribute.String("db.query.text", compactSQL(data.SQL)),
+	}
+	if id := logging.RequestID(ctx); id != "" {
+		attrs = append(attrs, attribute.String("request.id", id))
+	}
+
+	ctx, _ = tracer.Start(ctx, "db.query", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return ctx
+}
+
+func (QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	if data.Err != nil {
+		span.RecordError(data.Err)
+		span.SetStatus(codes.Error, data.Err.Error())
+		slog.DebugContext(ctx, "query failed", "error", data.Err)
+	}
+	span.SetAttributes(attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+	span.End()
+}
+
+// compactSQL схлопывает отступы многострочных запросов
+func compactSQL(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
@@ -0,0 +1,110 @@
+// Package errs - типизированные ошибки предметной области, общие для database, service и api
+package errs
+
+import (
+	"context"
+	"errors"
+)
+
+// Kind - категория ошибки, по которой api выбирает HTTP-статус
+type Kind int
+
+const (
+	Internal Kind = iota
+	Validation
+	NotFound
+	Conflict
+	Forbidden
+	Unavailable
+	// Клиент отменил запрос, не дождавшись ответа
+	Canceled
+	// Истек срок выполнения запроса
+	Timeout
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Validation:
+		return "validation"
+	case NotFound:
+		return "not_found"
+	case Conflict:
+		return "conflict"
+	case Forbidden:
+		return "forbidden"
+	case Unavailable:
+		return "unavailable"
+	case Canceled:
+		return "canceled"
+	case Timeout:
+		return "timeout"
+	default:
+		return "internal"
+	}
+}
+
+// Error - ошибка с категорией. Msg можно показывать клиенту,
+// Err - исходная причина, которая попадает только в логи
+type Error struct {
+	Kind Kind
+	Msg  string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Msg
+	}
+	return e.Msg + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New создает ошибку без причины, подходит для sentinel-значений
+func New(kind Kind, msg string) error {
+	return &Error{Kind: kind, Msg: msg}
+}
+
+// Wrap оборачивает причину err в ошибку категории kind с публичным сообщением msg
+func Wrap(kind Kind, msg string, err error) error {
+	return &Error{Kind: kind, Msg: msg, Err: err}
+}
+
+// KindOf возвращает категорию ближайшей типизированной ошибки в цепочке.
+// Ошибки контекста без типизированной ошибки относятся к Canceled и Timeout
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		return Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return Timeout
+	}
+	return Internal
+}
+
+// Is сообщает, относится ли err к категории kind
+func Is(err error, kind Kind) bool {
+	return err != nil && KindOf(err) == kind
+}
+
+// Message возвращает сообщение, безопасное для клиента. Для внутренних
+// ошибок подробности скрываются
+func Message(err error) string {
+	var e *Error
+	if errors.As(err, &e) && e.Kind != Internal {
+		return e.Msg
+	}
+	switch KindOf(err) {
+	case Canceled:
+		return "request canceled"
+	case Timeout:
+		return "request timed out"
+	}
+	return "internal server error"
+}
@@ -0,0 +1,29 @@
+package errs
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestKindOfContextErrors(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind Kind
+		msg  string
+	}{
+		{fmt.Errorf("failed to get order: %w", context.Canceled), Canceled, "request canceled"},
+		{fmt.Errorf("failed to get order: %w", context.DeadlineExceeded), Timeout, "request timed out"},
+		{Wrap(Timeout, "database request timed out", context.DeadlineExceeded), Timeout, "database request timed out"},
+		{Wrap(Unavailable, "database is unavailable", context.DeadlineExceeded), Unavailable, "database is unavailable"},
+		{fmt.Errorf("boom"), Internal, "internal server error"},
+	}
+	for _, tt := range tests {
+		if got := KindOf(tt.err); got != tt.kind {
+			t.Errorf("KindOf(%v) = %s, want %s", tt.err, got, tt.kind)
+		}
+		if got := Message(tt.err); got != tt.msg {
+			t.Errorf("Message(%v) = %q, want %q", tt.err, got, tt.msg)
+		}
+	}
+}
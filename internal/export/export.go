@@ -0,0 +1,222 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"order-service/internal/models"
+)
+
+type Format string
+
+const (
+	FormatJSONL   Format = "jsonl"
+	FormatCSV     Format = "csv"
+	FormatParquet Format = "parquet"
+)
+
+func ParseFormat(value string) (Format, error) {
+	switch Format(value) {
+	case FormatJSONL, FormatCSV, FormatParquet:
+		return Format(value), nil
+	case "":
+		return FormatJSONL, nil
+	}
+	return "", fmt.Errorf("unsupported export format %q", value)
+}
+
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatParquet:
+		return "application/vnd.apache.parquet"
+	}
+	return "application/x-ndjson"
+}
+
+// Writer последовательно пишет заказы в выбранном формате.
+// Close дописывает буферизованные данные, но не закрывает нижележащий io.Writer
+type Writer interface {
+	Write(order *models.Order) error
+	Close() error
+}
+
+// Число строк в группе parquet. Группа копится в памяти целиком до сброса,
+// поэтому без ограничения вся выгрузка оказалась бы в одной группе
+const parquetRowGroupRows = 10000
+
+func NewWriter(format Format, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatJSONL:
+		return &jsonlWriter{enc: json.NewEncoder(w)}, nil
+	case FormatCSV:
+		return newCSVWriter(w), nil
+	case FormatParquet:
+		return &parquetWriter{w: parquet.NewGenericWriter[row](w, parquet.MaxRowsPerRowGroup(parquetRowGroupRows))}, nil
+	}
+	return nil, fmt.Errorf("unsupported export format %q", format)
+}
+
+type jsonlWriter struct {
+	enc *json.Encoder
+}
+
+func (j *jsonlWriter) Write(order *models.Order) error {
+	return j.enc.Encode(order)
+}
+
+func (j *jsonlWriter) Close() error {
+	return nil
+}
+
+// row - плоское представление заказа: одна строка на каждый товар,
+// поля заказа, доставки и платежа повторяются
+type row struct {
+	OrderID         string    `parquet:"order_id"`
+	ClientID        int64     `parquet:"client_id"`
+	Locale          string    `parquet:"locale"`
+	DateCreated     time.Time `parquet:"date_created,timestamp(millisecond)"`
+	DeliveryName    string    `parquet:"delivery_name"`
+	DeliveryPhone   string    `parquet:"delivery_phone"`
+	DeliveryEmail   string    `parquet:"delivery_email"`
+	DeliveryType    string    `parquet:"delivery_type"`
+	DeliveryCity    string    `parquet:"delivery_city"`
+	DeliveryAddress string    `parquet:"delivery_address"`
+	TransactionID   string    `parquet:"payment_transaction_id"`
+	Currency        string    `parquet:"payment_currency"`
+	Provider        string    `parquet:"payment_provider"`
+	Amount          float64   `parquet:"payment_amount"`
+	DatePay         int64     `parquet:"payment_date_pay"`
+	Bank            string    `parquet:"payment_bank"`
+	ProductID       int64     `parquet:"item_product_id,optional"`
+	ItemName        string    `parquet:"item_name,optional"`
+	ItemBrand       string    `parquet:"item_brand,optional"`
+	ItemPrice       float64   `parquet:"item_price,optional"`
+	ItemSize        string    `parquet:"item_size,optional"`
+	ItemQuantity    int64     `parquet:"item_quantity,optional"`
+}
+
+var csvHeader = []string{
+	"order_id", "client_id", "locale", "date_created",
+	"delivery_name", "delivery_phone", "delivery_email", "delivery_type", "delivery_city", "delivery_address",
+	"payment_transaction_id", "payment_currency", "payment_provider", "payment_amount", "payment_date_pay", "payment_bank",
+	"item_product_id", "item_name", "item_brand", "item_price", "item_size", "item_quantity",
+}
+
+func flatten(order *models.Order) []row {
+	base := row{
+		OrderID:         order.OrderID,
+		ClientID:        order.ClientID,
+		Locale:          order.Locale,
+		DateCreated:     order.DateCreated,
+		DeliveryName:    order.Delivery.Name,
+		DeliveryPhone:   order.Delivery.Phone,
+		DeliveryEmail:   order.Delivery.Email,
+		DeliveryType:    order.Delivery.Type,
+		DeliveryCity:    order.Delivery.City,
+		DeliveryAddress: order.Delivery.Address,
+		TransactionID:   order.Payment.Transaction,
+		Currency:        order.Payment.Currency,
+		Provider:        order.Payment.Provider,
+		Amount:          order.Payment.Amount,
+		DatePay:         order.Payment.DatePay,
+		Bank:            order.Payment.Bank,
+	}
+
+	// Заказ без товаров все равно попадает в выгрузку одной строкой
+	if len(order.Items) == 0 {
+		return []row{base}
+	}
+
+	rows := make([]row, 0, len(order.Items))
+	for _, item := range order.Items {
+		r := base
+		r.ProductID = item.ProductID
+		r.ItemName = item.Name
+		r.ItemBrand = item.Brand
+		r.ItemPrice = item.Price
+		r.ItemSize = item.Size
+		r.ItemQuantity = int64(item.Quantity)
+		rows = append(rows, r)
+	}
+	return rows
+}
+
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) writeHeader() error {
+	if c.headerWritten {
+		return nil
+	}
+	c.headerWritten = true
+	return c.w.Write(csvHeader)
+}
+
+func (c *csvWriter) Write(order *models.Order) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	for _, r := range flatten(order) {
+		record := []string{
+			r.OrderID, strconv.FormatInt(r.ClientID, 10), r.Locale, r.DateCreated.Format(time.RFC3339),
+			r.DeliveryName, r.DeliveryPhone, r.DeliveryEmail, r.DeliveryType, r.DeliveryCity, r.DeliveryAddress,
+			r.TransactionID, r.Currency, r.Provider, formatFloat(r.Amount), strconv.FormatInt(r.DatePay, 10), r.Bank,
+			"", "", "", "", "", "",
+		}
+		if r.ProductID != 0 {
+			record[16] = strconv.FormatInt(r.ProductID, 10)
+			record[17] = r.ItemName
+			record[18] = r.ItemBrand
+			record[19] = formatFloat(r.ItemPrice)
+			record[20] = r.ItemSize
+			record[21] = strconv.FormatInt(r.ItemQuantity, 10)
+		}
+		if err := c.w.Write(record); err != nil {
+			return err
+		}
+	}
+
+	// Сбрасываем буфер после каждого заказа, чтобы данные уходили клиенту потоком
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+type parquetWriter struct {
+	w *parquet.GenericWriter[row]
+}
+
+func (p *parquetWriter) Write(order *models.Order) error {
+	_, err := p.w.Write(flatten(order))
+	return err
+}
+
+func (p *parquetWriter) Close() error {
+	return p.w.Close()
+}
@@ -0,0 +1,47 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"order-service/internal/models"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// TestParquetSplitsRowGroups проверяет, что parquet-выгрузка сбрасывается
+// группами, а не копится в памяти до Close
+func TestParquetSplitsRowGroups(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(FormatParquet, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const orders = parquetRowGroupRows*2 + 1
+	for i := range orders {
+		order := &models.Order{OrderID: fmt.Sprintf("o%d", i), DateCreated: time.Now()}
+		if err := w.Write(order); err != nil {
+			t.Fatal(err)
+		}
+		if i == parquetRowGroupRows && buf.Len() == 0 {
+			t.Fatal("first row group was not flushed before Close")
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := parquet.OpenFile(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.NumRows() != orders {
+		t.Fatalf("rows = %d, want %d", file.NumRows(), orders)
+	}
+	if groups := len(file.RowGroups()); groups != 3 {
+		t.Fatalf("row groups = %d, want 3", groups)
+	}
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"order-service/internal/cache"
+)
+
+const namespace = "order_service"
+
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	grpcRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "grpc_request_duration_seconds",
+		Help:      "gRPC call latency by method and status code. Streams are observed when they end.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "code"})
+
+	dbQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Database call latency by PostgresBase method.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"method"})
+
+	ordersSaved = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orders_saved_total",
+		Help:      "Orders created or updated through the API.",
+	}, []string{"result"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequestDuration,
+		grpcRequestDuration,
+		dbQueryDuration,
+		ordersSaved,
+	)
+}
+
+// Register подключает метрики пула соединений и кэша
+func Register(pool *pgxpool.Pool, c *cache.Cache) {
+	registry.MustRegister(newPoolCollector(pool), newCacheCollector(c))
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+func ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	httpRequestDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+func ObserveGRPCRequest(method, code string, duration time.Duration) {
+	grpcRequestDuration.WithLabelValues(method, code).Observe(duration.Seconds())
+}
+
+// ObserveDBQuery предназначена для defer в начале метода: defer metrics.ObserveDBQuery("GetOrder", time.Now())
+func ObserveDBQuery(method string, start time.Time) {
+	dbQueryDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+func ObserveOrdersSaved(n int, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	ordersSaved.WithLabelValues(result).Add(float64(n))
+}
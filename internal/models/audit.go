@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+// Операции журнала изменений заказов
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+	// Обезличивание доставки по запросу клиента
+	AuditErase = "erase"
+)
+
+// AuditEntry - запись журнала изменений заказа. Diff содержит только
+// изменившиеся поля, персональные данные доставки в нем скрыты
+type AuditEntry struct {
+	AuditID   int64                  `json:"audit_id"`
+	OrderID   string                 `json:"order_id"`
+	Operation string                 `json:"operation"`
+	Actor     string                 `json:"actor"`
+	RequestID string                 `json:"request_id,omitempty"`
+	ChangedAt time.Time              `json:"changed_at"`
+	Diff      map[string]FieldChange `json:"diff"`
+}
+
+type FieldChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// OrderFilter - критерии отбора заказов для выгрузки и списков
+type OrderFilter struct {
+	ClientID int64
+	// Ограничение по доступным вызывающему клиентам, nil - без ограничений
+	ClientIDs   []int64
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	Limit       int
+	// Продолжить выборку после этого заказа, nil - с начала
+	After *OrderCursor
+}
+
+// OrderCursor - позиция заказа в порядке выборки: новые первыми, при равной
+// дате создания - по order_id
+type OrderCursor struct {
+	DateCreated time.Time
+	OrderID     string
+}
+
+// ParseFilterTime принимает дату в формате RFC3339 или YYYY-MM-DD
+func ParseFilterTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q: expected RFC3339 or YYYY-MM-DD", value)
+	}
+	return t, nil
+}
@@ -40,3 +40,16 @@ type Product struct {
 	Size      string  `json:"size"`
 	Quantity  int     `json:"quantity"`
 }
+
+// Clone возвращает глубокую копию заказа, не разделяющую память с оригиналом
+func (o *Order) Clone() *Order {
+	if o == nil {
+		return nil
+	}
+	clone := *o
+	if o.Items != nil {
+		clone.Items = make([]Product, len(o.Items))
+		copy(clone.Items, o.Items)
+	}
+	return &clone
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// DataSubject - клиент, по которому выполняется запрос на выгрузку или удаление
+// персональных данных. Задается ровно одно поле
+type DataSubject struct {
+	ClientID int64  `json:"client_id,omitempty"`
+	Email    string `json:"email,omitempty"`
+}
+
+func (s DataSubject) Validate() error {
+	email := strings.TrimSpace(s.Email)
+	switch {
+	case s.ClientID == 0 && email == "":
+		return errors.New("client_id or email is required")
+	case s.ClientID != 0 && email != "":
+		return errors.New("only one of client_id and email may be set")
+	case s.ClientID < 0:
+		return errors.New("client_id must be positive")
+	case email != "" && !strings.Contains(email, "@"):
+		return errors.New("invalid email")
+	}
+	return nil
+}
+
+// SubjectDataExport - все заказы клиента с доставкой для передачи по его запросу
+type SubjectDataExport struct {
+	RequestID   int64       `json:"request_id"`
+	Subject     DataSubject `json:"subject"`
+	GeneratedAt time.Time   `json:"generated_at"`
+	Orders      []*Order    `json:"orders"`
+}
+
+// SubjectErasure - итог обезличивания данных клиента
+type SubjectErasure struct {
+	RequestID int64       `json:"request_id"`
+	Subject   DataSubject `json:"subject"`
+	ErasedAt  time.Time   `json:"erased_at"`
+	OrderIDs  []string    `json:"order_ids"`
+}
@@ -0,0 +1,174 @@
+package pii
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Зашифрованное значение хранится строкой enc:v1:<key id>:<base64(nonce || ciphertext)>.
+// Значения без префикса считаются открытыми (записаны до включения шифрования)
+const encPrefix = "enc:v1:"
+
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// Keyring шифрует поля AES-256-GCM. Новые значения шифруются активным ключом,
+// расшифровываются любым ключом из набора, что позволяет ротацию: новый ключ
+// делается активным, старый остается в наборе до перешифрования данных.
+//
+// Методы nil Keyring работают как выключенное шифрование
+type Keyring struct {
+	active   string
+	aeads    map[string]cipher.AEAD
+	indexKey []byte
+}
+
+// NewKeyring разбирает ключи вида "k2:<base64>,k1:<base64>" (по 32 байта).
+// active - ID ключа для шифрования, пустой - первый в списке. indexKey -
+// base64-ключ HMAC для слепого индекса. Пустой keys выключает шифрование (nil, nil)
+func NewKeyring(keys, active, indexKey string) (*Keyring, error) {
+	if strings.TrimSpace(keys) == "" {
+		return nil, nil
+	}
+
+	k := &Keyring{aeads: make(map[string]cipher.AEAD)}
+	for _, entry := range strings.Split(keys, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("invalid encryption key entry: expected <id>:<base64 key> with id of letters, digits, _ or -")
+		}
+		if _, dup := k.aeads[id]; dup {
+			return nil, fmt.Errorf("duplicate encryption key id %q", id)
+		}
+		key, err := decodeKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %q: %w", id, err)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %q: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %q: %w", id, err)
+		}
+		k.aeads[id] = aead
+		if k.active == "" {
+			k.active = id
+		}
+	}
+
+	if active != "" {
+		if _, ok := k.aeads[active]; !ok {
+			return nil, fmt.Errorf("active encryption key %q is not in the key list", active)
+		}
+		k.active = active
+	}
+
+	if indexKey == "" {
+		return nil, errors.New("blind index key is required when encryption is enabled")
+	}
+	var err error
+	if k.indexKey, err = decodeKey(indexKey); err != nil {
+		return nil, fmt.Errorf("blind index key: %w", err)
+	}
+
+	return k, nil
+}
+
+func decodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key must be 32 bytes, got %d", len(key))
+	}
+	return key, nil
+}
+
+// ActiveKeyID возвращает ID ключа, которым шифруются новые значения
+func (k *Keyring) ActiveKeyID() string {
+	if k == nil {
+		return ""
+	}
+	return k.active
+}
+
+// Encrypt шифрует значение активным ключом. aad привязывает шифртекст к месту
+// хранения (например, колонке и order_id), чтобы его нельзя было перенести в другую строку.
+// Пустая строка не шифруется
+func (k *Keyring) Encrypt(plaintext, aad string) (string, error) {
+	if k == nil || plaintext == "" {
+		return plaintext, nil
+	}
+
+	aead := k.aeads[k.active]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(aad))
+
+	return encPrefix + k.active + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt расшифровывает значение, записанное Encrypt. Открытые значения возвращаются как есть
+func (k *Keyring) Decrypt(value, aad string) (string, error) {
+	rest, encrypted := strings.CutPrefix(value, encPrefix)
+	if !encrypted {
+		return value, nil
+	}
+	if k == nil {
+		return "", errors.New("value is encrypted but no encryption keys are configured")
+	}
+
+	id, encoded, ok := strings.Cut(rest, ":")
+	if !ok {
+		return "", errors.New("malformed encrypted value")
+	}
+	aead, ok := k.aeads[id]
+	if !ok {
+		return "", fmt.Errorf("unknown encryption key id %q", id)
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed encrypted value")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(aad))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value with key %q: %w", id, err)
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation сообщает, что значение хранится открытым или зашифровано не активным ключом
+func (k *Keyring) NeedsRotation(value string) bool {
+	if k == nil || value == "" {
+		return false
+	}
+	return !strings.HasPrefix(value, encPrefix+k.active+":")
+}
+
+// BlindIndex возвращает детерминированный HMAC значения для поиска по точному
+// совпадению без расшифровки. Регистр и пробелы по краям не учитываются.
+// Без ключей и для пустого значения возвращает пустую строку
+func (k *Keyring) BlindIndex(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if k == nil || value == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, k.indexKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,171 @@
+package pii
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+func testKeyring(t *testing.T, keys, active string) *Keyring {
+	t.Helper()
+	k, err := NewKeyring(keys, active, testKey(9))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestNewKeyring(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    string
+		active  string
+		index   string
+		wantErr bool
+	}{
+		{"disabled", "", "", "", false},
+		{"single key", "k1:" + testKey(1), "", testKey(9), false},
+		{"explicit active", "k1:" + testKey(1) + ",k2:" + testKey(2), "k2", testKey(9), false},
+		{"unknown active", "k1:" + testKey(1), "k2", testKey(9), true},
+		{"duplicate id", "k1:" + testKey(1) + ",k1:" + testKey(2), "", testKey(9), true},
+		{"short key", "k1:" + base64.StdEncoding.EncodeToString([]byte("short")), "", testKey(9), true},
+		{"bad base64", "k1:not base64!", "", testKey(9), true},
+		{"bad id", "k 1:" + testKey(1), "", testKey(9), true},
+		{"no index key", "k1:" + testKey(1), "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeyring(tt.keys, tt.active, tt.index)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	k := testKeyring(t, "k1:"+testKey(1), "")
+
+	enc, err := k.Encrypt("Иван Петров", "delivery.name:o1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(enc, "enc:v1:k1:") || strings.Contains(enc, "Иван") {
+		t.Fatalf("unexpected ciphertext %q", enc)
+	}
+	again, _ := k.Encrypt("Иван Петров", "delivery.name:o1")
+	if again == enc {
+		t.Error("same nonce used twice")
+	}
+
+	got, err := k.Decrypt(enc, "delivery.name:o1")
+	if err != nil || got != "Иван Петров" {
+		t.Fatalf("Decrypt = %q, %v", got, err)
+	}
+
+	// Пустое и открытое значения проходят как есть
+	if enc, _ := k.Encrypt("", "aad"); enc != "" {
+		t.Errorf("empty value encrypted to %q", enc)
+	}
+	if got, err := k.Decrypt("plain", "aad"); err != nil || got != "plain" {
+		t.Errorf("Decrypt(plain) = %q, %v", got, err)
+	}
+}
+
+func TestDecryptRejects(t *testing.T) {
+	k := testKeyring(t, "k1:"+testKey(1), "")
+	enc, err := k.Encrypt("secret", "delivery.email:o1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		keyring *Keyring
+		value   string
+		aad     string
+	}{
+		// Шифртекст нельзя перенести в другую строку или колонку
+		{"other aad", k, enc, "delivery.email:o2"},
+		{"wrong key", testKeyring(t, "k1:"+testKey(2), ""), enc, "delivery.email:o1"},
+		{"unknown key id", testKeyring(t, "k2:"+testKey(1), ""), enc, "delivery.email:o1"},
+		{"no keys", nil, enc, "delivery.email:o1"},
+		{"tampered", k, enc[:len(enc)-2] + "AA", "delivery.email:o1"},
+		{"malformed", k, "enc:v1:k1", "delivery.email:o1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := tt.keyring.Decrypt(tt.value, tt.aad); err == nil {
+				t.Fatalf("Decrypt = %q, want error", got)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	old := testKeyring(t, "k1:"+testKey(1), "")
+	enc, err := old.Encrypt("secret", "aad")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Новый ключ активен, старый остается в наборе для чтения
+	rotated := testKeyring(t, "k2:"+testKey(2)+",k1:"+testKey(1), "k2")
+	if rotated.ActiveKeyID() != "k2" {
+		t.Fatalf("active key = %q", rotated.ActiveKeyID())
+	}
+	if got, err := rotated.Decrypt(enc, "aad"); err != nil || got != "secret" {
+		t.Fatalf("Decrypt with old key = %q, %v", got, err)
+	}
+	if !rotated.NeedsRotation(enc) {
+		t.Error("value under old key does not need rotation")
+	}
+	if !rotated.NeedsRotation("plain") {
+		t.Error("plaintext value does not need rotation")
+	}
+
+	reenc, err := rotated.Encrypt("secret", "aad")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(reenc, "enc:v1:k2:") || rotated.NeedsRotation(reenc) {
+		t.Errorf("re-encrypted value %q is not under the active key", reenc)
+	}
+	if rotated.NeedsRotation("") {
+		t.Error("empty value needs rotation")
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	k := testKeyring(t, "k1:"+testKey(1), "")
+
+	idx := k.BlindIndex("Ivan@Example.com")
+	if idx == "" || strings.Contains(idx, "ivan") {
+		t.Fatalf("BlindIndex = %q", idx)
+	}
+	if k.BlindIndex("  ivan@example.com ") != idx {
+		t.Error("index depends on case or surrounding spaces")
+	}
+	if k.BlindIndex("petr@example.com") == idx {
+		t.Error("different values share an index")
+	}
+
+	// Индекс зависит от ключа, а не только от значения
+	other, err := NewKeyring("k1:"+testKey(1), "", testKey(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.BlindIndex("ivan@example.com") == idx {
+		t.Error("index does not depend on the index key")
+	}
+
+	var disabled *Keyring
+	if disabled.BlindIndex("ivan@example.com") != "" || k.BlindIndex(" ") != "" {
+		t.Error("expected empty index without keys or value")
+	}
+}
@@ -0,0 +1,97 @@
+package pii
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"order-service/internal/auth"
+	"order-service/internal/models"
+)
+
+// MaskPolicy скрывает персональные данные доставки от ролей, которым они не нужны
+// целиком. Нулевое значение ничего не маскирует. Запросы без principal (внутренние
+// вызовы или выключенная аутентификация) не маскируются
+type MaskPolicy struct {
+	enabled  bool
+	unmasked map[auth.Role]bool
+}
+
+// NewMaskPolicy маскирует данные для всех ролей, кроме перечисленных
+func NewMaskPolicy(unmaskedRoles []auth.Role) MaskPolicy {
+	p := MaskPolicy{enabled: true, unmasked: make(map[auth.Role]bool, len(unmaskedRoles))}
+	for _, role := range unmaskedRoles {
+		p.unmasked[role] = true
+	}
+	return p
+}
+
+// Apply маскирует заказ на месте, если этого требует роль вызывающего
+func (p MaskPolicy) Apply(ctx context.Context, order *models.Order) {
+	if !p.enabled || order == nil {
+		return
+	}
+	principal := auth.FromContext(ctx)
+	if principal == nil || p.unmasked[principal.Role] {
+		return
+	}
+	MaskDelivery(&order.Delivery)
+}
+
+// MaskDelivery оставляет от персональных данных только то, что нужно для
+// опознания заказа: инициалы, последние цифры телефона, домен почты
+func MaskDelivery(d *models.Delivery) {
+	d.Name = maskName(d.Name)
+	d.Phone = maskPhone(d.Phone)
+	d.Email = MaskEmail(d.Email)
+	if d.Address != "" {
+		d.Address = "***"
+	}
+}
+
+// "Иван Петров" -> "И*** П***"
+func maskName(name string) string {
+	words := strings.Fields(name)
+	for i, w := range words {
+		r := []rune(w)
+		words[i] = string(r[0]) + "***"
+	}
+	return strings.Join(words, " ")
+}
+
+// "+79161234567" -> "+7******4567"
+func maskPhone(phone string) string {
+	digits := 0
+	for _, r := range phone {
+		if unicode.IsDigit(r) {
+			digits++
+		}
+	}
+
+	var b strings.Builder
+	seen := 0
+	for _, r := range phone {
+		if unicode.IsDigit(r) {
+			seen++
+			// Код страны (первая цифра) и последние четыре цифры остаются
+			if seen > 1 && seen <= digits-4 {
+				r = '*'
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// MaskEmail оставляет первый символ имени и домен: "ivan@example.com" -> "i***@example.com"
+func MaskEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || local == "" {
+		if email == "" {
+			return ""
+		}
+		return "***"
+	}
+	r := []rune(local)
+	return string(r[0]) + "***@" + domain
+}
@@ -0,0 +1,76 @@
+package pii
+
+import (
+	"context"
+	"testing"
+
+	"order-service/internal/auth"
+	"order-service/internal/models"
+)
+
+func testDelivery() models.Delivery {
+	return models.Delivery{
+		Name:    "Иван Петров",
+		Phone:   "+79161234567",
+		Email:   "ivan@example.com",
+		Address: "ул. Ленина, 1",
+		City:    "Moscow",
+	}
+}
+
+func TestMaskDelivery(t *testing.T) {
+	d := testDelivery()
+	MaskDelivery(&d)
+
+	want := models.Delivery{
+		Name:    "И*** П***",
+		Phone:   "+7******4567",
+		Email:   "i***@example.com",
+		Address: "***",
+		City:    "Moscow",
+	}
+	if d != want {
+		t.Fatalf("MaskDelivery = %+v, want %+v", d, want)
+	}
+
+	empty := models.Delivery{}
+	MaskDelivery(&empty)
+	if empty != (models.Delivery{}) {
+		t.Errorf("empty delivery masked to %+v", empty)
+	}
+	if got := MaskEmail("no-at-sign"); got != "***" {
+		t.Errorf("MaskEmail(no-at-sign) = %q", got)
+	}
+}
+
+func TestMaskPolicyByRole(t *testing.T) {
+	policy := NewMaskPolicy([]auth.Role{auth.RoleAdmin, auth.RoleService})
+
+	tests := []struct {
+		name      string
+		policy    MaskPolicy
+		principal *auth.Principal
+		masked    bool
+	}{
+		{"admin", policy, &auth.Principal{Role: auth.RoleAdmin}, false},
+		{"service", policy, &auth.Principal{Role: auth.RoleService}, false},
+		{"support", policy, &auth.Principal{Role: auth.RoleSupport}, true},
+		{"partner", policy, &auth.Principal{Role: auth.RolePartner}, true},
+		{"no principal", policy, nil, false},
+		{"zero policy", MaskPolicy{}, &auth.Principal{Role: auth.RolePartner}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.principal != nil {
+				ctx = auth.WithPrincipal(ctx, tt.principal)
+			}
+			order := &models.Order{OrderID: "o1", Delivery: testDelivery()}
+			tt.policy.Apply(ctx, order)
+
+			if masked := order.Delivery != testDelivery(); masked != tt.masked {
+				t.Fatalf("masked = %v, want %v: %+v", masked, tt.masked, order.Delivery)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit - параметры token bucket: Rate токенов в секунду, не больше Burst в запасе
+type Limit struct {
+	Rate  float64
+	Burst int
+	// Окно, в котором задан лимит, для заголовка RateLimit-Policy
+	Window time.Duration
+}
+
+// ParseLimit разбирает лимит вида "<n>/<s|m|h>[:burst]", например "10/s" или "100/m:20".
+// По умолчанию burst равен n
+func ParseLimit(value string) (Limit, error) {
+	spec, burstStr, hasBurst := strings.Cut(strings.TrimSpace(value), ":")
+	countStr, unit, ok := strings.Cut(spec, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: expected <n>/<s|m|h>[:burst]", value)
+	}
+
+	count, err := strconv.Atoi(countStr)
+	if err != nil || count <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: count must be a positive integer", value)
+	}
+
+	var window time.Duration
+	switch unit {
+	case "s":
+		window = time.Second
+	case "m":
+		window = time.Minute
+	case "h":
+		window = time.Hour
+	default:
+		return Limit{}, fmt.Errorf("invalid rate limit %q: unit must be s, m or h", value)
+	}
+
+	burst := count
+	if hasBurst {
+		burst, err = strconv.Atoi(burstStr)
+		if err != nil || burst <= 0 {
+			return Limit{}, fmt.Errorf("invalid rate limit %q: burst must be a positive integer", value)
+		}
+	}
+
+	return Limit{Rate: float64(count) / window.Seconds(), Burst: burst, Window: window}, nil
+}
+
+// Quota - лимит в окне Window, для заголовков ответа
+func (l Limit) Quota() int {
+	return int(math.Round(l.Rate * l.Window.Seconds()))
+}
+
+// Result - решение по запросу и состояние корзины после него
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// Через сколько корзина заполнится полностью
+	Reset time.Duration
+	// Через сколько появится следующий токен, если запрос отклонен
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Как часто вычищать корзины простаивающих клиентов
+const sweepInterval = time.Minute
+
+// Limiter ведет отдельную корзину на каждый ключ (клиента)
+type Limiter struct {
+	limit Limit
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func New(limit Limit) *Limiter {
+	return &Limiter{limit: limit, buckets: make(map[string]*bucket), lastSweep: time.Now()}
+}
+
+func (l *Limiter) Limit() Limit {
+	return l.limit
+}
+
+// Allow списывает токен из корзины key, если он есть
+func (l *Limiter) Allow(key string) Result {
+	return l.take(key, true)
+}
+
+// Check сообщает, есть ли в корзине key токен, не списывая его
+func (l *Limiter) Check(key string) Result {
+	return l.take(key, false)
+}
+
+func (l *Limiter) take(key string, spend bool) Result {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+
+	tokens := float64(l.limit.Burst)
+	b, ok := l.buckets[key]
+	if ok {
+		tokens = l.refill(b, now)
+	}
+
+	res := Result{Allowed: tokens >= 1}
+	if !res.Allowed {
+		res.RetryAfter = l.wait(1 - tokens)
+	} else if spend {
+		tokens--
+	}
+	// Пустая корзина не заводится, пока из нее ничего не списано
+	if ok || spend {
+		if !ok {
+			b = &bucket{}
+			l.buckets[key] = b
+		}
+		b.tokens = tokens
+		b.updated = now
+	}
+	res.Remaining = int(tokens)
+	res.Reset = l.wait(float64(l.limit.Burst) - tokens)
+	return res
+}
+
+func (l *Limiter) refill(b *bucket, now time.Time) float64 {
+	return math.Min(float64(l.limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*l.limit.Rate)
+}
+
+func (l *Limiter) wait(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / l.limit.Rate * float64(time.Second))
+}
+
+// sweep удаляет заполнившиеся корзины: их состояние совпадает с новой корзиной
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if l.refill(b, now) >= float64(l.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
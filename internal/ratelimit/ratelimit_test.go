@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Limit
+		wantErr bool
+	}{
+		{value: "10/s", want: Limit{Rate: 10, Burst: 10, Window: time.Second}},
+		{value: "120/m:20", want: Limit{Rate: 2, Burst: 20, Window: time.Minute}},
+		{value: "3600/h", want: Limit{Rate: 1, Burst: 3600, Window: time.Hour}},
+		{value: "10", wantErr: true},
+		{value: "0/s", wantErr: true},
+		{value: "10/d", wantErr: true},
+		{value: "10/s:0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseLimit(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("ParseLimit = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllow(t *testing.T) {
+	l := New(Limit{Rate: 1, Burst: 2, Window: time.Second})
+
+	for i, wantRemaining := range []int{1, 0} {
+		res := l.Allow("a")
+		if !res.Allowed || res.Remaining != wantRemaining {
+			t.Fatalf("request %d: %+v", i+1, res)
+		}
+	}
+	res := l.Allow("a")
+	if res.Allowed || res.RetryAfter <= 0 || res.RetryAfter > time.Second {
+		t.Fatalf("over limit: %+v", res)
+	}
+
+	// Корзины ключей независимы
+	if res := l.Allow("b"); !res.Allowed {
+		t.Fatalf("other key: %+v", res)
+	}
+}
+
+func TestCheckDoesNotSpend(t *testing.T) {
+	l := New(Limit{Rate: 1, Burst: 1, Window: time.Hour})
+
+	for range 3 {
+		if res := l.Check("a"); !res.Allowed || res.Remaining != 1 {
+			t.Fatalf("Check on fresh bucket: %+v", res)
+		}
+	}
+	l.Allow("a")
+	if res := l.Check("a"); res.Allowed || res.RetryAfter <= 0 {
+		t.Fatalf("Check after spending the last token: %+v", res)
+	}
+}
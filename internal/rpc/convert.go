@@ -0,0 +1,139 @@
+package rpc
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"order-service/internal/models"
+	"order-service/internal/rpc/orderpb"
+)
+
+func toProto(order *models.Order) *orderpb.Order {
+	pb := &orderpb.Order{
+		OrderId:  order.OrderID,
+		ClientId: order.ClientID,
+		Locale:   order.Locale,
+		Delivery: &orderpb.Delivery{
+			Name:    order.Delivery.Name,
+			Phone:   order.Delivery.Phone,
+			Email:   order.Delivery.Email,
+			Type:    order.Delivery.Type,
+			City:    order.Delivery.City,
+			Address: order.Delivery.Address,
+		},
+		Payment: &orderpb.Payment{
+			TransactionId: order.Payment.Transaction,
+			Currency:      order.Payment.Currency,
+			Provider:      order.Payment.Provider,
+			Amount:        order.Payment.Amount,
+			DatePay:       order.Payment.DatePay,
+			Bank:          order.Payment.Bank,
+		},
+	}
+	if !order.DateCreated.IsZero() {
+		pb.DateCreated = timestamppb.New(order.DateCreated)
+	}
+	for _, item := range order.Items {
+		pb.Items = append(pb.Items, &orderpb.Product{
+			ProductId: item.ProductID,
+			Name:      item.Name,
+			Brand:     item.Brand,
+			Price:     item.Price,
+			Size:      item.Size,
+			Quantity:  int32(item.Quantity),
+		})
+	}
+	return pb
+}
+
+func fromProto(pb *orderpb.Order) *models.Order {
+	order := &models.Order{
+		OrderID:     pb.GetOrderId(),
+		ClientID:    pb.GetClientId(),
+		Locale:      pb.GetLocale(),
+		DateCreated: timeFromProto(pb.GetDateCreated()),
+	}
+	if d := pb.GetDelivery(); d != nil {
+		order.Delivery = models.Delivery{
+			Name:    d.GetName(),
+			Phone:   d.GetPhone(),
+			Email:   d.GetEmail(),
+			Type:    d.GetType(),
+			City:    d.GetCity(),
+			Address: d.GetAddress(),
+		}
+	}
+	if p := pb.GetPayment(); p != nil {
+		order.Payment = models.Payment{
+			Transaction: p.GetTransactionId(),
+			Currency:    p.GetCurrency(),
+			Provider:    p.GetProvider(),
+			Amount:      p.GetAmount(),
+			DatePay:     p.GetDatePay(),
+			Bank:        p.GetBank(),
+		}
+	}
+	for _, item := range pb.GetItems() {
+		order.Items = append(order.Items, models.Product{
+			ProductID: item.GetProductId(),
+			Name:      item.GetName(),
+			Brand:     item.GetBrand(),
+			Price:     item.GetPrice(),
+			Size:      item.GetSize(),
+			Quantity:  int(item.GetQuantity()),
+		})
+	}
+	return order
+}
+
+// timeFromProto переводит отсутствующую метку времени в нулевое время
+func timeFromProto(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
+
+// pageToken - курсор ListOrders. Фильтр запроса хранится в токене, чтобы
+// продолжение с другим фильтром не пропускало и не повторяло заказы
+type pageToken struct {
+	DateCreated time.Time `json:"t"`
+	OrderID     string    `json:"id"`
+	Filter      string    `json:"f"`
+}
+
+func filterKey(filter models.OrderFilter) string {
+	return fmt.Sprintf("%d|%d|%d", filter.ClientID, unixNano(filter.CreatedFrom), unixNano(filter.CreatedTo))
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func encodePageToken(cursor models.OrderCursor, filter models.OrderFilter) string {
+	data, _ := json.Marshal(pageToken{DateCreated: cursor.DateCreated, OrderID: cursor.OrderID, Filter: filterKey(filter)})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodePageToken(value string, filter models.OrderFilter) (*models.OrderCursor, error) {
+	var token pageToken
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err == nil {
+		err = json.Unmarshal(data, &token)
+	}
+	if err != nil || token.OrderID == "" {
+		return nil, errors.New("invalid page_token")
+	}
+	if token.Filter != filterKey(filter) {
+		return nil, errors.New("page_token does not match the request filter")
+	}
+	return &models.OrderCursor{DateCreated: token.DateCreated, OrderID: token.OrderID}, nil
+}
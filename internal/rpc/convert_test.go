@@ -0,0 +1,36 @@
+package rpc
+
+import (
+	"testing"
+	"time"
+
+	"order-service/internal/models"
+)
+
+func TestPageToken(t *testing.T) {
+	filter := models.OrderFilter{ClientID: 7, CreatedFrom: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), Limit: 101}
+	cursor := models.OrderCursor{DateCreated: time.Date(2026, 3, 5, 10, 20, 30, 123456000, time.UTC), OrderID: "o42"}
+	token := encodePageToken(cursor, filter)
+
+	// Размер страницы между запросами может меняться
+	next := filter
+	next.Limit = 11
+	got, err := decodePageToken(token, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.DateCreated.Equal(cursor.DateCreated) || got.OrderID != cursor.OrderID {
+		t.Fatalf("cursor = %+v, want %+v", got, cursor)
+	}
+
+	other := filter
+	other.ClientID = 8
+	if _, err := decodePageToken(token, other); err == nil {
+		t.Error("token accepted for another filter")
+	}
+	for _, bad := range []string{"not base64!", "e30"} {
+		if _, err := decodePageToken(bad, filter); err == nil {
+			t.Errorf("token %q accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package rpc
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"order-service/internal/auth"
+	"order-service/internal/errs"
+	"order-service/internal/logging"
+	"order-service/internal/metrics"
+	"order-service/internal/ratelimit"
+	"order-service/internal/rpc/orderpb"
+)
+
+// Права, необходимые для методов OrderService. Метод, которого нет в списке,
+// доступен без аутентификации, только если он из publicServices
+var methodScopes = map[string]auth.Scope{
+	orderpb.OrderService_GetOrder_FullMethodName:    auth.ScopeRead,
+	orderpb.OrderService_CreateOrder_FullMethodName: auth.ScopeWrite,
+	orderpb.OrderService_ListOrders_FullMethodName:  auth.ScopeRead,
+	orderpb.OrderService_WatchOrders_FullMethodName: auth.ScopeRead,
+}
+
+// Сервисы, открытые без аутентификации: проверки оркестратора
+var publicServices = []string{"/grpc.health.v1.Health/"}
+
+// Limits - ограничения частоты вызовов, те же, что у HTTP API
+type Limits struct {
+	// Лимит вызовов каждого метода для клиента, nil - не ограничивается
+	Rate *ratelimit.Limit
+	// Лимит неудачных попыток аутентификации с одного IP, nil - не ограничивается
+	AuthFailureRate *ratelimit.Limit
+}
+
+// guard аутентифицирует вызовы и ограничивает их частоту
+type guard struct {
+	auth *auth.Authenticator
+	// Лимитеры методов из methodScopes
+	limiters     map[string]*ratelimit.Limiter
+	authFailures *ratelimit.Limiter
+}
+
+func newGuard(authenticator *auth.Authenticator, limits Limits) *guard {
+	g := &guard{auth: authenticator, limiters: make(map[string]*ratelimit.Limiter)}
+	if limits.Rate != nil {
+		for method := range methodScopes {
+			g.limiters[method] = ratelimit.New(*limits.Rate)
+		}
+	}
+	if limits.AuthFailureRate != nil {
+		g.authFailures = ratelimit.New(*limits.AuthFailureRate)
+	}
+	return g
+}
+
+func (g *guard) unaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		ctx = withRequestID(ctx)
+
+		ctx, err := g.admit(ctx, info.FullMethod)
+		var resp any
+		if err == nil {
+			resp, err = handler(ctx, req)
+		}
+		err = toStatus(ctx, err)
+		finishCall(ctx, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+func (g *guard) streamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx := withRequestID(ss.Context())
+
+		ctx, err := g.admit(ctx, info.FullMethod)
+		if err == nil {
+			err = handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		}
+		err = toStatus(ctx, err)
+		finishCall(ctx, info.FullMethod, start, err)
+		return err
+	}
+}
+
+// admit аутентифицирует вызов и списывает его из лимита клиента. Поток
+// WatchOrders учитывается один раз при открытии
+func (g *guard) admit(ctx context.Context, method string) (context.Context, error) {
+	ctx, err := g.authenticate(ctx, method)
+	if err != nil {
+		return ctx, err
+	}
+	limiter := g.limiters[method]
+	if limiter == nil {
+		return ctx, nil
+	}
+
+	client := peerKey(ctx)
+	if auth.FromContext(ctx) != nil {
+		client = auth.Actor(ctx)
+	}
+	if res := limiter.Allow(client); !res.Allowed {
+		slog.InfoContext(ctx, "rate limit exceeded", "method", method, "client", client)
+		return ctx, retryLater("rate limit exceeded", res.RetryAfter)
+	}
+	return ctx, nil
+}
+
+// serverStream подменяет контекст потока, чтобы в нем были principal и request ID
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// withRequestID берет x-request-id из метаданных или создает новый и возвращает его клиенту
+func withRequestID(ctx context.Context) context.Context {
+	requestID := firstValue(ctx, "x-request-id")
+	if !logging.ValidRequestID(requestID) {
+		requestID = logging.NewRequestID()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs("x-request-id", requestID))
+	return logging.WithRequestID(ctx, requestID)
+}
+
+// authenticate проверяет учетные данные из метаданных authorization и x-api-key
+// и право на вызов метода. При выключенной аутентификации вызовы проходят без проверки.
+// IP, с которого исчерпан лимит неудачных попыток, получает RESOURCE_EXHAUSTED
+// без проверки учетных данных
+func (g *guard) authenticate(ctx context.Context, method string) (context.Context, error) {
+	scope, secured := methodScopes[method]
+	if !secured {
+		for _, prefix := range publicServices {
+			if strings.HasPrefix(method, prefix) {
+				return ctx, nil
+			}
+		}
+		return ctx, status.Error(codes.PermissionDenied, "method is not allowed")
+	}
+	if !g.auth.Enabled() {
+		return ctx, nil
+	}
+
+	if g.authFailures != nil {
+		if res := g.authFailures.Check(peerKey(ctx)); !res.Allowed {
+			slog.InfoContext(ctx, "too many failed authentication attempts", "method", method, "client", peerKey(ctx))
+			return ctx, retryLater("too many failed authentication attempts", res.RetryAfter)
+		}
+	}
+
+	principal, err := g.auth.AuthenticateHeaders(firstValue(ctx, "authorization"), firstValue(ctx, "x-api-key"))
+	if err != nil {
+		if g.authFailures != nil {
+			g.authFailures.Allow(peerKey(ctx))
+		}
+		slog.InfoContext(ctx, "authentication failed", "method", method, "error", err)
+		return ctx, status.Error(codes.Unauthenticated, "authentication required")
+	}
+	if !principal.Has(scope) {
+		slog.InfoContext(ctx, "access denied", "method", method, "subject", principal.Subject, "required_scope", scope)
+		return ctx, status.Error(codes.PermissionDenied, "insufficient scope: "+string(scope)+" required")
+	}
+	return auth.WithPrincipal(ctx, principal), nil
+}
+
+// peerKey - ключ анонимного клиента для лимитов: IP, с которого пришло соединение
+func peerKey(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "ip:unknown"
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return "ip:" + p.Addr.String()
+	}
+	return "ip:" + host
+}
+
+// retryLater - ответ RESOURCE_EXHAUSTED с RetryInfo, аналог 429 с Retry-After
+func retryLater(msg string, after time.Duration) error {
+	st := status.New(codes.ResourceExhausted, msg)
+	if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(after)}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+func firstValue(ctx context.Context, key string) string {
+	values := metadata.ValueFromIncomingContext(ctx, key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// toStatus переводит ошибки errs в коды gRPC. Как и в HTTP, подробности
+// внутренних ошибок остаются в логах
+func toStatus(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	var code codes.Code
+	switch errs.KindOf(err) {
+	case errs.Validation:
+		code = codes.InvalidArgument
+	case errs.NotFound:
+		code = codes.NotFound
+	case errs.Conflict:
+		code = codes.Aborted
+	case errs.Forbidden:
+		code = codes.PermissionDenied
+	case errs.Unavailable:
+		code = codes.Unavailable
+	case errs.Canceled:
+		code = codes.Canceled
+	case errs.Timeout:
+		code = codes.DeadlineExceeded
+	default:
+		code = codes.Internal
+		slog.ErrorContext(ctx, "grpc call failed", "error", err)
+	}
+	return status.Error(code, errs.Message(err))
+}
+
+// finishCall пишет access-лог и метрику вызова
+func finishCall(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err).String()
+	elapsed := time.Since(start)
+	slog.InfoContext(ctx, "grpc call",
+		"method", method,
+		"code", code,
+		"latency_ms", float64(elapsed.Microseconds())/1000,
+	)
+	metrics.ObserveGRPCRequest(method, code, elapsed)
+}
@@ -0,0 +1,130 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"order-service/internal/auth"
+	"order-service/internal/cache"
+	"order-service/internal/models"
+	"order-service/internal/ratelimit"
+	"order-service/internal/rpc/orderpb"
+	"order-service/internal/service"
+)
+
+type testServer struct {
+	client orderpb.OrderServiceClient
+	keys   map[string]string
+}
+
+// newTestServer поднимает сервер в памяти с API-ключами names. Заказ o1 лежит
+// в кэше, поэтому GetOrder обходится без БД
+func newTestServer(t *testing.T, limits Limits, names ...string) *testServer {
+	t.Helper()
+	ts := &testServer{keys: map[string]string{}}
+	var yaml strings.Builder
+	yaml.WriteString("keys:\n")
+	for _, name := range names {
+		key, hash := auth.GenerateAPIKey()
+		ts.keys[name] = key
+		fmt.Fprintf(&yaml, "  - name: %s\n    hash: %s\n    scopes: [read]\n    role: admin\n", name, hash)
+	}
+	keysFile := filepath.Join(t.TempDir(), "keys.yaml")
+	if err := os.WriteFile(keysFile, []byte(yaml.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	authenticator, err := auth.New(auth.Config{APIKeysFile: keysFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orders := cache.NewCache()
+	orders.Set(&models.Order{OrderID: "o1", ClientID: 1})
+	server := NewServer(service.NewOrderService(nil, orders), authenticator, limits)
+
+	lis := bufconn.Listen(1 << 20)
+	go server.Serve(lis)
+	t.Cleanup(func() { server.Shutdown(context.Background()) })
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	ts.client = orderpb.NewOrderServiceClient(conn)
+	return ts
+}
+
+// get запрашивает заказ с ключом name, пустое имя - неверный ключ
+func (ts *testServer) get(name string) error {
+	key := "wrong"
+	if name != "" {
+		key = ts.keys[name]
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", key)
+	_, err := ts.client.GetOrder(ctx, &orderpb.GetOrderRequest{OrderId: "o1"})
+	return err
+}
+
+func mustLimit(t *testing.T, value string) *ratelimit.Limit {
+	t.Helper()
+	limit, err := ratelimit.ParseLimit(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &limit
+}
+
+func TestRateLimitPerKey(t *testing.T) {
+	ts := newTestServer(t, Limits{Rate: mustLimit(t, "1/h")}, "alice", "bob")
+
+	if err := ts.get("alice"); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	err := ts.get("alice")
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("over limit: %v, want RESOURCE_EXHAUSTED", err)
+	}
+	var retry *errdetails.RetryInfo
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			retry = info
+		}
+	}
+	if retry == nil || retry.GetRetryDelay().AsDuration() <= 0 {
+		t.Errorf("RetryInfo = %v", retry)
+	}
+
+	// Ключи с одного адреса считаются отдельно
+	if err := ts.get("bob"); err != nil {
+		t.Errorf("other key: %v", err)
+	}
+}
+
+func TestAuthFailureLimit(t *testing.T) {
+	ts := newTestServer(t, Limits{AuthFailureRate: mustLimit(t, "1/h")}, "alice")
+
+	if err := ts.get(""); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("wrong key: %v, want UNAUTHENTICATED", err)
+	}
+	// Адрес заблокирован до проверки учетных данных
+	if err := ts.get("alice"); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("after failed attempt: %v, want RESOURCE_EXHAUSTED", err)
+	}
+}
@@ -0,0 +1,207 @@
+// Package rpc - gRPC API заказов. Использует тот же OrderService, что и HTTP API,
+// поэтому права доступа, маскирование и кэш работают одинаково
+package rpc
+
+//go:generate protoc -I ../../proto --go_out=../.. --go_opt=module=order-service --go-grpc_out=../.. --go-grpc_opt=module=order-service order/v1/order.proto
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"order-service/internal/auth"
+	"order-service/internal/errs"
+	"order-service/internal/models"
+	"order-service/internal/rpc/orderpb"
+	"order-service/internal/service"
+)
+
+// Таймаут обработки unary-вызова, если клиент не задал более короткий deadline
+const callTimeout = 5 * time.Second
+
+// Размер страницы ListOrders по умолчанию и максимальный
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+// Server - gRPC-сервер с OrderService и стандартной проверкой здоровья
+type Server struct {
+	grpc   *grpc.Server
+	health *health.Server
+
+	// Отменяется при остановке, чтобы завершить бесконечные потоки WatchOrders
+	streams      context.Context
+	closeStreams context.CancelFunc
+}
+
+// NewServer создает сервер. opts дополняют настройки, например TLS
+func NewServer(orders *service.OrderService, authenticator *auth.Authenticator, limits Limits, opts ...grpc.ServerOption) *Server {
+	g := newGuard(authenticator, limits)
+	opts = append(opts,
+		// Продолжает трассу клиента из метаданных и открывает span вызова
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(g.unaryInterceptor()),
+		grpc.ChainStreamInterceptor(g.streamInterceptor()),
+	)
+
+	s := &Server{
+		grpc:   grpc.NewServer(opts...),
+		health: health.NewServer(),
+	}
+	s.streams, s.closeStreams = context.WithCancel(context.Background())
+
+	orderpb.RegisterOrderServiceServer(s.grpc, &orderServer{service: orders, streams: s.streams})
+	healthpb.RegisterHealthServer(s.grpc, s.health)
+	return s
+}
+
+// Serve принимает соединения до вызова Shutdown
+func (s *Server) Serve(lis net.Listener) error {
+	return s.grpc.Serve(lis)
+}
+
+// Shutdown переводит проверку здоровья в NOT_SERVING, закрывает потоки WatchOrders
+// (клиенты переподключатся к другой реплике) и ждет завершения остальных вызовов.
+// Если ctx истекает раньше, оставшиеся вызовы обрываются
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.health.Shutdown()
+	s.closeStreams()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.grpc.Stop()
+		return ctx.Err()
+	}
+}
+
+type orderServer struct {
+	orderpb.UnimplementedOrderServiceServer
+
+	service *service.OrderService
+	streams context.Context
+}
+
+func (s *orderServer) GetOrder(ctx context.Context, req *orderpb.GetOrderRequest) (*orderpb.Order, error) {
+	if req.GetOrderId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_id is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	order, err := s.service.GetOrder(ctx, req.GetOrderId())
+	if err != nil {
+		return nil, err
+	}
+	if order == nil {
+		return nil, status.Error(codes.NotFound, "order not found")
+	}
+	return toProto(order), nil
+}
+
+func (s *orderServer) CreateOrder(ctx context.Context, req *orderpb.CreateOrderRequest) (*orderpb.CreateOrderResponse, error) {
+	if req.GetOrder().GetOrderId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order.order_id is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	order := fromProto(req.GetOrder())
+	if err := s.service.SaveOrder(ctx, order); err != nil {
+		return nil, err
+	}
+	return &orderpb.CreateOrderResponse{OrderId: order.OrderID}, nil
+}
+
+func (s *orderServer) ListOrders(ctx context.Context, req *orderpb.ListOrdersRequest) (*orderpb.ListOrdersResponse, error) {
+	pageSize := int(req.GetPageSize())
+	switch {
+	case pageSize < 0 || pageSize > maxPageSize:
+		return nil, status.Errorf(codes.InvalidArgument, "page_size must be between 0 and %d", maxPageSize)
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	filter := models.OrderFilter{
+		ClientID:    req.GetClientId(),
+		CreatedFrom: timeFromProto(req.GetCreatedFrom()),
+		CreatedTo:   timeFromProto(req.GetCreatedTo()),
+		// Лишний заказ показывает, что есть следующая страница
+		Limit: pageSize + 1,
+	}
+	if req.GetPageToken() != "" {
+		after, err := decodePageToken(req.GetPageToken(), filter)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		filter.After = after
+	}
+
+	var orders []*models.Order
+	err := s.service.ExportOrders(ctx, filter, func(order *models.Order) error {
+		orders = append(orders, order)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &orderpb.ListOrdersResponse{}
+	if len(orders) > pageSize {
+		orders = orders[:pageSize]
+		last := orders[len(orders)-1]
+		resp.NextPageToken = encodePageToken(models.OrderCursor{DateCreated: last.DateCreated, OrderID: last.OrderID}, filter)
+	}
+	for _, order := range orders {
+		resp.Orders = append(resp.Orders, toProto(order))
+	}
+	return resp, nil
+}
+
+func (s *orderServer) WatchOrders(req *orderpb.WatchOrdersRequest, stream grpc.ServerStreamingServer[orderpb.OrderEvent]) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	stop := context.AfterFunc(s.streams, cancel)
+	defer stop()
+
+	err := s.service.WatchOrders(ctx, req.GetClientId(), func(change service.OrderChange) error {
+		event := &orderpb.OrderEvent{Type: orderpb.OrderEvent_TYPE_DELETED, OrderId: change.OrderID}
+		if change.Order != nil {
+			event.Type = orderpb.OrderEvent_TYPE_UPSERTED
+			event.Order = toProto(change.Order)
+		}
+		return stream.Send(event)
+	})
+	if errors.Is(err, service.ErrWatchOverflow) {
+		// Клиент должен увидеть, что поток оборван из-за отставания, и переподписаться
+		return status.Error(codes.ResourceExhausted, errs.Message(err))
+	}
+	if err != nil {
+		return err
+	}
+	if s.streams.Err() != nil {
+		return status.Error(codes.Unavailable, "server is shutting down")
+	}
+	return nil
+}
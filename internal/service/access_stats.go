@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// accessStats копит обращения к заказам в памяти до следующего сброса в БД
+type accessStats struct {
+	mu   sync.Mutex
+	hits map[string]int64
+}
+
+func (a *accessStats) record(orderID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.hits == nil {
+		a.hits = make(map[string]int64)
+	}
+	a.hits[orderID]++
+}
+
+// restore возвращает счетчики после неудачного сброса, чтобы не потерять их
+func (a *accessStats) restore(hits map[string]int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.hits == nil {
+		a.hits = make(map[string]int64, len(hits))
+	}
+	for id, n := range hits {
+		a.hits[id] += n
+	}
+}
+
+func (a *accessStats) take() map[string]int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	hits := a.hits
+	a.hits = nil
+	return hits
+}
+
+// FlushAccessStats сохраняет накопленную статистику обращений для прогрева по стратегии top
+func (s *OrderService) FlushAccessStats(ctx context.Context) error {
+	hits := s.access.take()
+	if err := s.db.RecordAccessStats(ctx, hits); err != nil {
+		s.access.restore(hits)
+		return err
+	}
+	return nil
+}
+
+// RunAccessStatsFlusher периодически сбрасывает статистику в БД и делает
+// последний сброс при отмене ctx
+func (s *OrderService) RunAccessStatsFlusher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.FlushAccessStats(ctx); err != nil {
+				slog.WarnContext(ctx, "failed to flush access stats", "error", err)
+			}
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := s.FlushAccessStats(flushCtx); err != nil {
+				slog.WarnContext(flushCtx, "failed to flush access stats", "error", err)
+			}
+			cancel()
+			return
+		}
+	}
+}
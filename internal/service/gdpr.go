@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"order-service/internal/auth"
+	"order-service/internal/errs"
+	"order-service/internal/models"
+)
+
+// checkSubject проверяет запрос и доступ к клиенту. Для поиска по email
+// ограничение по клиентам применяется в самой выборке
+func checkSubject(ctx context.Context, subject models.DataSubject) error {
+	if err := subject.Validate(); err != nil {
+		return errs.New(errs.Validation, "invalid data subject: "+err.Error())
+	}
+	if subject.ClientID != 0 && !auth.FromContext(ctx).CanAccessClient(subject.ClientID) {
+		return fmt.Errorf("%w: client_id %d", ErrForbidden, subject.ClientID)
+	}
+	return nil
+}
+
+// ExportSubjectData собирает все заказы клиента с полными данными доставки.
+// Маскирование не применяется: выгрузка предназначена самому клиенту
+func (s *OrderService) ExportSubjectData(ctx context.Context, subject models.DataSubject) (*models.SubjectDataExport, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.ExportSubjectData")
+	defer span.End()
+
+	if err := checkSubject(ctx, subject); err != nil {
+		return nil, err
+	}
+
+	allowed, _ := auth.FromContext(ctx).AllowedClients()
+	result, err := s.db.ExportSubjectData(ctx, subject, allowed, auth.Actor(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to export subject data: %w", err)
+	}
+
+	span.SetAttributes(attribute.Int("orders.count", len(result.Orders)))
+	slog.InfoContext(ctx, "subject data exported", "request_id", result.RequestID, "orders", len(result.Orders))
+	return result, nil
+}
+
+// EraseSubjectData обезличивает доставку во всех заказах клиента и убирает
+// эти заказы из кэша
+func (s *OrderService) EraseSubjectData(ctx context.Context, subject models.DataSubject) (*models.SubjectErasure, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.EraseSubjectData")
+	defer span.End()
+
+	if err := checkSubject(ctx, subject); err != nil {
+		return nil, err
+	}
+
+	allowed, _ := auth.FromContext(ctx).AllowedClients()
+	result, err := s.db.EraseSubjectData(ctx, subject, allowed, auth.Actor(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to erase subject data: %w", err)
+	}
+
+	// Другие реплики обновят кэш по уведомлениям об изменении delivery
+	for _, id := range result.OrderIDs {
+		s.cache.Delete(id)
+	}
+	// Перезаписываем снимок кэша, чтобы стертые данные не пережили перезапуск
+	if len(result.OrderIDs) > 0 {
+		s.cache.RequestSnapshot()
+	}
+
+	span.SetAttributes(attribute.Int("orders.count", len(result.OrderIDs)))
+	slog.InfoContext(ctx, "subject data erased", "request_id", result.RequestID, "orders", len(result.OrderIDs))
+	return result, nil
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"order-service/internal/models"
+)
+
+// RunCacheInvalidation подписывается на изменения заказов в БД (в том числе с других реплик),
+// вытесняет измененные заказы из кэша и рассылает изменения подписчикам WatchOrders до отмены ctx
+func (s *OrderService) RunCacheInvalidation(ctx context.Context) {
+	// Рассылка подписчикам читает заказ из БД отдельно, чтобы медленный запрос
+	// не задерживал обработку следующих уведомлений
+	changes := make(chan string, watchQueue)
+	defer close(changes)
+	go s.publishChanges(ctx, changes)
+
+	s.db.ListenOrderChanges(ctx,
+		func(orderID string) {
+			s.evictChanged(orderID)
+			s.queueChange(changes, orderID)
+		},
+		func() {
+			// Пока соединения не было, часть уведомлений потеряна - доверять кэшу нельзя
+			s.fills.invalidateAll()
+			s.cache.Clear()
+			slog.InfoContext(ctx, "order change listener reconnected, cache cleared")
+		},
+	)
+}
+
+// evictChanged убирает измененный заказ из кэша, следующий запрос перечитает
+// его из БД. Обращения к БД здесь нет, чтобы медленный запрос не задерживал
+// обработку остальных уведомлений
+func (s *OrderService) evictChanged(orderID string) {
+	// Чтения из БД, начатые до изменения, не должны вернуть в кэш старую версию,
+	// а новые промахи не должны присоединяться к ним
+	s.fills.invalidate(orderID)
+	s.fetches.Forget(orderID)
+	// Удаляет и возможную отрицательную запись
+	s.cache.Delete(orderID)
+}
+
+// fillGuard не дает записать в кэш результат чтения из БД, если во время
+// чтения пришло уведомление об изменении этого заказа
+type fillGuard struct {
+	mu sync.Mutex
+	// Счетчик уведомлений об изменениях
+	seq uint64
+	// Заказы, которые сейчас читаются из БД
+	inflight map[string]*fill
+	// Идущие потоковые чтения, например прогрев кэша
+	scans map[*scan]struct{}
+}
+
+// scan - потоковое чтение, заказы которого заранее неизвестны. Запоминает
+// заказы, измененные с начала чтения
+type scan struct {
+	changed map[string]bool
+	// Кэш сброшен целиком, ни одна прочитанная строка не годится
+	all bool
+}
+
+type fill struct {
+	refs int
+	// Значение seq при последнем изменении заказа во время чтения
+	invalidated uint64
+}
+
+// begin отмечает начало чтения заказов ids из БД. Результат передается в set
+func (g *fillGuard) begin(ids ...string) uint64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.inflight == nil {
+		g.inflight = make(map[string]*fill)
+	}
+	for _, id := range ids {
+		f := g.inflight[id]
+		if f == nil {
+			f = &fill{}
+			g.inflight[id] = f
+		}
+		f.refs++
+	}
+	return g.seq
+}
+
+// end отмечает завершение чтения, начатого begin с теми же ids
+func (g *fillGuard) end(ids ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, id := range ids {
+		if f := g.inflight[id]; f != nil {
+			if f.refs--; f.refs == 0 {
+				delete(g.inflight, id)
+			}
+		}
+	}
+}
+
+func (g *fillGuard) invalidate(id string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.seq++
+	if f := g.inflight[id]; f != nil {
+		f.invalidated = g.seq
+	}
+	for sc := range g.scans {
+		sc.changed[id] = true
+	}
+}
+
+// invalidateAll отменяет запись в кэш для всех чтений, идущих сейчас
+func (g *fillGuard) invalidateAll() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.seq++
+	for _, f := range g.inflight {
+		f.invalidated = g.seq
+	}
+	for sc := range g.scans {
+		sc.all = true
+	}
+}
+
+// set вызывает store, только если заказ id не менялся с момента begin.
+// store выполняется под блокировкой, чтобы изменение не вклинилось между
+// проверкой и записью в кэш
+func (g *fillGuard) set(id string, started uint64, store func()) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if f := g.inflight[id]; f != nil && f.invalidated > started {
+		return false
+	}
+	store()
+	return true
+}
+
+// beginScan отмечает начало потокового чтения. После него нужно вызвать endScan
+func (g *fillGuard) beginScan() *scan {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.scans == nil {
+		g.scans = make(map[*scan]struct{})
+	}
+	sc := &scan{changed: make(map[string]bool)}
+	g.scans[sc] = struct{}{}
+	return sc
+}
+
+func (g *fillGuard) endScan(sc *scan) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.scans, sc)
+}
+
+// setScanned передает в store заказы из batch, которые не менялись с начала
+// чтения sc, и возвращает их число. batch фильтруется на месте
+func (g *fillGuard) setScanned(sc *scan, batch []models.Order, store func([]models.Order)) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if sc.all {
+		return 0
+	}
+	fresh := batch[:0]
+	for _, order := range batch {
+		if !sc.changed[order.OrderID] {
+			fresh = append(fresh, order)
+		}
+	}
+	store(fresh)
+	return len(fresh)
+}
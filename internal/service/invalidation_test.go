@@ -0,0 +1,87 @@
+package service
+
+import (
+	"testing"
+
+	"order-service/internal/cache"
+	"order-service/internal/models"
+)
+
+// TestFillGuardDropsStaleFill воспроизводит гонку: чтение из БД началось,
+// пришло уведомление об изменении, затем чтение пытается записать старую версию
+func TestFillGuardDropsStaleFill(t *testing.T) {
+	s := NewOrderService(nil, cache.NewCache())
+	stale := &models.Order{OrderID: "o1", Locale: "stale"}
+
+	started := s.fills.begin("o1")
+	s.fills.invalidate("o1")
+	if s.fills.set("o1", started, func() { s.cache.Set(stale) }) {
+		t.Fatal("stale fill was accepted")
+	}
+	s.fills.end("o1")
+	if s.cache.Contains("o1") {
+		t.Fatal("stale order was written to the cache")
+	}
+
+	// Изменения других заказов не мешают записи
+	started = s.fills.begin("o1")
+	s.fills.invalidate("o2")
+	if !s.fills.set("o1", started, func() { s.cache.Set(stale) }) {
+		t.Fatal("fill was rejected after an unrelated change")
+	}
+	s.fills.end("o1")
+	if len(s.fills.inflight) != 0 {
+		t.Fatalf("inflight fills leaked: %v", s.fills.inflight)
+	}
+}
+
+func TestFillGuardOverlappingFills(t *testing.T) {
+	var g fillGuard
+
+	first := g.begin("o1")
+	g.invalidate("o1")
+	// Чтение, начатое после изменения, видит новую версию
+	second := g.begin("o1")
+
+	if g.set("o1", first, func() {}) {
+		t.Fatal("fill started before the change was accepted")
+	}
+	if !g.set("o1", second, func() {}) {
+		t.Fatal("fill started after the change was rejected")
+	}
+	g.end("o1")
+	g.end("o1")
+
+	started := g.begin("o1", "o2")
+	g.invalidateAll()
+	if g.set("o2", started, func() {}) {
+		t.Fatal("fill survived invalidateAll")
+	}
+	g.end("o1", "o2")
+	if len(g.inflight) != 0 {
+		t.Fatalf("inflight fills leaked: %v", g.inflight)
+	}
+}
+
+// TestEvictChanged проверяет, что уведомление вытесняет заказ и отрицательную
+// запись и не дает начатому чтению вернуть старую версию
+func TestEvictChanged(t *testing.T) {
+	s := NewOrderService(nil, cache.NewCache())
+	s.cache.Set(&models.Order{OrderID: "o1"})
+	s.cache.SetMissing("o2")
+
+	started := s.fills.begin("o1")
+	s.evictChanged("o1")
+	s.evictChanged("o2")
+
+	if s.cache.Contains("o1") {
+		t.Fatal("changed order stayed in the cache")
+	}
+	if s.cache.IsMissing("o2") {
+		t.Fatal("negative entry survived a change notification")
+	}
+	if s.fills.set("o1", started, func() { s.cache.Set(&models.Order{OrderID: "o1"}) }) {
+		t.Fatal("read started before the change refilled the cache")
+	}
+	s.fills.end("o1")
+}
@@ -2,17 +2,49 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
+
+	"order-service/internal/auth"
 	"order-service/internal/cache"
 	"order-service/internal/database"
+	"order-service/internal/errs"
+	"order-service/internal/metrics"
 	"order-service/internal/models"
+	"order-service/internal/pii"
+	"order-service/internal/telemetry"
 )
 
+var tracer = telemetry.Tracer("service")
+
+// Таймаут общего похода в БД при промахе кэша. Не зависит от контекста
+// первого запроса, чтобы его отмена не роняла остальных ожидающих
+const fetchTimeout = 5 * time.Second
+
 type OrderService struct {
 	db    *database.PostgresBase
 	cache *cache.Cache
+
+	// Объединяет одновременные промахи по одному order_id в один запрос к БД
+	fetches singleflight.Group
+	// Не дает чтениям из БД перезаписать кэш после уведомления об изменении
+	fills fillGuard
+
+	warmup warmupState
+	access accessStats
+
+	// Маскирование персональных данных в ответах по роли вызывающего
+	mask pii.MaskPolicy
+
+	// Подписчики на изменения заказов (WatchOrders)
+	watchers watchers
 }
 
 func NewOrderService(db *database.PostgresBase, cache *cache.Cache) *OrderService {
@@ -22,47 +54,332 @@ func NewOrderService(db *database.PostgresBase, cache *cache.Cache) *OrderServic
 	}
 }
 
+// ErrForbidden - заказ относится к клиенту, к которому у вызывающего нет доступа
+var ErrForbidden = errs.New(errs.Forbidden, "access to this client's orders is denied")
+
+// Доступ к данным определяется principal из контекста (см. auth.Principal.AllowedClients).
+// Проверки выполняются здесь, а не в обработчиках, чтобы их нельзя было обойти
+// новым маршрутом. Чужие заказы при чтении выглядят как несуществующие
+
+// checkSave проверяет, что вызывающий может записывать заказы клиента order.ClientID
+func checkSave(ctx context.Context, order *models.Order) error {
+	if !auth.FromContext(ctx).CanAccessClient(order.ClientID) {
+		return fmt.Errorf("%w: client_id %d", ErrForbidden, order.ClientID)
+	}
+	return nil
+}
+
+// saveError переводит отказ БД перезаписать чужой заказ в ErrForbidden
+func saveError(err error) error {
+	if errors.Is(err, database.ErrForeignOrder) {
+		return fmt.Errorf("%w: %w", ErrForbidden, err)
+	}
+	return fmt.Errorf("failed to save order to DB: %w", err)
+}
+
+// SetMaskPolicy задает маскирование персональных данных в возвращаемых заказах.
+// По умолчанию данные не маскируются
+func (s *OrderService) SetMaskPolicy(policy pii.MaskPolicy) {
+	s.mask = policy
+}
+
 func (s *OrderService) SaveOrder(ctx context.Context, order *models.Order) error {
+	ctx, span := tracer.Start(ctx, "OrderService.SaveOrder", trace.WithAttributes(attribute.String("order.id", order.OrderID)))
+	defer span.End()
+
+	if err := checkSave(ctx, order); err != nil {
+		return err
+	}
+
 	// Сохраняем в БД
-	if err := s.db.SaveOrder(ctx, order); err != nil {
-		return fmt.Errorf("failed to save order to DB: %w", err)
+	allowed, _ := auth.FromContext(ctx).AllowedClients()
+	err := s.db.SaveOrder(ctx, order, allowed)
+	metrics.ObserveOrdersSaved(1, err)
+	if err != nil {
+		return saveError(err)
 	}
 
 	// Сохраняем в кэш
+	_, cacheSpan := tracer.Start(ctx, "cache.Set")
 	s.cache.Set(order)
+	cacheSpan.End()
 
-	log.Printf("Order %s saved successfully", order.OrderID)
+	slog.InfoContext(ctx, "order saved", "order_id", order.OrderID)
 	return nil
 }
 
+// ErrBatchAborted - заказ не сохранен, потому что в атомарном пакете упал другой заказ
+var ErrBatchAborted = errs.New(errs.Conflict, "batch aborted: another order in the batch failed")
+
+// SaveOrders сохраняет пакет заказов и возвращает ошибку для каждого заказа по индексу (nil - успех).
+// В атомарном режиме используется одна транзакция, иначе - отдельная транзакция на каждый заказ
+func (s *OrderService) SaveOrders(ctx context.Context, orders []*models.Order, atomic bool) []error {
+	ctx, span := tracer.Start(ctx, "OrderService.SaveOrders", trace.WithAttributes(
+		attribute.Int("orders.count", len(orders)),
+		attribute.Bool("batch.atomic", atomic),
+	))
+	defer span.End()
+
+	results := make([]error, len(orders))
+
+	if !atomic {
+		for i, order := range orders {
+			results[i] = s.SaveOrder(ctx, order)
+		}
+		return results
+	}
+
+	// Заказ чужого клиента отменяет атомарный пакет еще до обращения к БД
+	for i, order := range orders {
+		if err := checkSave(ctx, order); err != nil {
+			for j := range results {
+				results[j] = ErrBatchAborted
+			}
+			results[i] = err
+			return results
+		}
+	}
+
+	allowed, _ := auth.FromContext(ctx).AllowedClients()
+	err := s.db.SaveOrders(ctx, orders, allowed)
+	metrics.ObserveOrdersSaved(len(orders), err)
+	if err != nil {
+		var batchErr *database.BatchError
+		for i := range results {
+			results[i] = ErrBatchAborted
+		}
+		if errors.As(err, &batchErr) {
+			results[batchErr.Index] = saveError(batchErr.Err)
+		} else {
+			// Ошибка не относится к конкретному заказу (например, commit)
+			for i := range results {
+				results[i] = fmt.Errorf("failed to save orders to DB: %w", err)
+			}
+		}
+		return results
+	}
+
+	for _, order := range orders {
+		s.cache.Set(order)
+	}
+	slog.InfoContext(ctx, "order batch saved", "orders", len(orders))
+
+	return results
+}
+
 func (s *OrderService) GetOrder(ctx context.Context, orderID string) (*models.Order, error) {
+	order, _, err := s.LookupOrder(ctx, orderID)
+	return order, err
+}
+
+// LookupOrder работает как GetOrder и дополнительно сообщает, был ли ответ получен из кэша
+func (s *OrderService) LookupOrder(ctx context.Context, orderID string) (*models.Order, bool, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.GetOrder", trace.WithAttributes(attribute.String("order.id", orderID)))
+	defer span.End()
+
 	// Пытаемся получить из кэша
-	if order, exists := s.cache.Get(orderID); exists {
+	_, cacheSpan := tracer.Start(ctx, "cache.Get")
+	order, exists := s.cache.Get(orderID)
+	// Заказ недавно искали и не нашли
+	missing := !exists && s.cache.IsMissing(orderID)
+	cacheSpan.SetAttributes(attribute.Bool("cache.hit", exists), attribute.Bool("cache.negative_hit", missing))
+	cacheSpan.End()
+
+	if exists {
+		if !auth.FromContext(ctx).CanAccessClient(order.ClientID) {
+			return nil, true, nil
+		}
+		s.access.record(orderID)
+		s.mask.Apply(ctx, order)
+		return order, true, nil
+	}
+	if missing {
+		return nil, true, nil
+	}
+
+	// Если нет в кэше, ищем в БД - один запрос на все одновременные промахи
+	result := s.fetches.DoChan(orderID, func() (any, error) {
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), fetchTimeout)
+		defer cancel()
+
+		started := s.fills.begin(orderID)
+		defer s.fills.end(orderID)
+
+		order, err := s.db.GetOrder(fetchCtx, orderID)
+		if err != nil {
+			return nil, err
+		}
+
+		// Если нашли в БД, сохраняем в кэш, иначе запоминаем отсутствие.
+		// Если заказ успел измениться, кэш не трогаем
+		s.fills.set(orderID, started, func() {
+			if order != nil {
+				s.cache.Set(order)
+			} else {
+				s.cache.SetMissing(orderID)
+			}
+		})
 		return order, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	case res := <-result:
+		if res.Err != nil {
+			span.RecordError(res.Err)
+			span.SetStatus(codes.Error, "failed to get order from DB")
+			return nil, false, fmt.Errorf("failed to get order from DB: %w", res.Err)
+		}
+		// Результат общий для всех ожидающих, каждому отдаем свою копию
+		order := res.Val.(*models.Order).Clone()
+		if order == nil || !auth.FromContext(ctx).CanAccessClient(order.ClientID) {
+			return nil, false, nil
+		}
+		s.access.record(orderID)
+		s.mask.Apply(ctx, order)
+		return order, false, nil
 	}
+}
 
-	// Если нет в кэше, ищем в БД
-	order, err := s.db.GetOrder(ctx, orderID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get order from DB: %w", err)
+// GetOrders возвращает найденные заказы в порядке запроса и список отсутствующих ID.
+// Попадания берутся из кэша, промахи догружаются из БД одним запросом
+func (s *OrderService) GetOrders(ctx context.Context, orderIDs []string) ([]*models.Order, []string, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.GetOrders", trace.WithAttributes(attribute.Int("orders.requested", len(orderIDs))))
+	defer span.End()
+
+	_, cacheSpan := tracer.Start(ctx, "cache.Get")
+	found := make(map[string]*models.Order, len(orderIDs))
+	var misses []string
+	for _, id := range orderIDs {
+		if _, seen := found[id]; seen {
+			continue
+		}
+		order, exists := s.cache.Get(id)
+		found[id] = order
+		if !exists && !s.cache.IsMissing(id) {
+			misses = append(misses, id)
+		}
 	}
+	cacheSpan.SetAttributes(attribute.Int("cache.misses", len(misses)))
+	cacheSpan.End()
 
-	// Если нашли в БД, сохраняем в кэш
-	if order != nil {
-		s.cache.Set(order)
+	if len(misses) > 0 {
+		started := s.fills.begin(misses...)
+		orders, err := s.db.GetOrders(ctx, misses)
+		if err != nil {
+			s.fills.end(misses...)
+			return nil, nil, fmt.Errorf("failed to get orders from DB: %w", err)
+		}
+		for _, order := range orders {
+			s.fills.set(order.OrderID, started, func() { s.cache.Set(order) })
+			found[order.OrderID] = order
+		}
+		for _, id := range misses {
+			if found[id] == nil {
+				s.fills.set(id, started, func() { s.cache.SetMissing(id) })
+			}
+		}
+		s.fills.end(misses...)
+	}
+
+	principal := auth.FromContext(ctx)
+	orders := make([]*models.Order, 0, len(found))
+	missing := make([]string, 0)
+	for _, id := range orderIDs {
+		order, ok := found[id]
+		if !ok {
+			// Повторный ID в запросе уже обработан
+			continue
+		}
+		delete(found, id)
+		if order == nil || !principal.CanAccessClient(order.ClientID) {
+			missing = append(missing, id)
+			continue
+		}
+		s.access.record(id)
+		// Заказы из БД уже скопированы в кэш, маскировать их на месте безопасно
+		s.mask.Apply(ctx, order)
+		orders = append(orders, order)
+	}
+
+	return orders, missing, nil
+}
+
+// ExportOrders передает в fn заказы, подходящие под фильтр, по мере чтения из БД.
+// Кэш не используется: выгрузка всегда отражает текущее состояние базы.
+// Выборка ограничивается доступными вызывающему клиентами, а явный запрос
+// чужого client_id отклоняется с ErrForbidden
+func (s *OrderService) ExportOrders(ctx context.Context, filter models.OrderFilter, fn func(*models.Order) error) error {
+	ctx, span := tracer.Start(ctx, "OrderService.ExportOrders")
+	defer span.End()
+
+	principal := auth.FromContext(ctx)
+	if filter.ClientID != 0 && !principal.CanAccessClient(filter.ClientID) {
+		return fmt.Errorf("%w: client_id %d", ErrForbidden, filter.ClientID)
+	}
+	if allowed, restricted := principal.AllowedClients(); restricted {
+		filter.ClientIDs = allowed
 	}
 
-	return order, nil
+	masked := func(order *models.Order) error {
+		s.mask.Apply(ctx, order)
+		return fn(order)
+	}
+	if err := s.db.StreamOrders(ctx, filter, masked); err != nil {
+		return fmt.Errorf("failed to export orders: %w", err)
+	}
+	return nil
 }
 
-func (s *OrderService) LoadCacheFromDB(ctx context.Context) error {
-	orders, err := s.db.GetAllOrders(ctx)
+// Максимальное число записей журнала в одном ответе
+const maxHistoryLimit = 1000
+
+// OrderHistory возвращает журнал изменений заказа, новые записи первыми.
+// Журнал чужого клиента выглядит пустым, как и сам заказ
+func (s *OrderService) OrderHistory(ctx context.Context, orderID string, limit int) ([]models.AuditEntry, error) {
+	ctx, span := tracer.Start(ctx, "OrderService.OrderHistory", trace.WithAttributes(attribute.String("order.id", orderID)))
+	defer span.End()
+
+	if limit <= 0 || limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+
+	allowed, _ := auth.FromContext(ctx).AllowedClients()
+	entries, err := s.db.GetOrderHistory(ctx, orderID, allowed, limit)
 	if err != nil {
-		return fmt.Errorf("failed to load orders from DB: %w", err)
+		return nil, fmt.Errorf("failed to get order history: %w", err)
 	}
+	return entries, nil
+}
 
-	s.cache.LoadFromSlice(orders)
-	log.Printf("Loaded %d orders into cache", len(orders))
+func (s *OrderService) CacheStats() cache.Stats {
+	return s.cache.Stats()
+}
+
+// EvictCached удаляет заказ из кэша и сообщает, был ли он там
+func (s *OrderService) EvictCached(orderID string) bool {
+	existed := s.cache.Contains(orderID)
+	s.cache.Delete(orderID)
+	return existed
+}
+
+func (s *OrderService) ClearCache(ctx context.Context) {
+	s.cache.Clear()
+	slog.InfoContext(ctx, "cache cleared")
+}
 
+// CheckDB проверяет доступность БД и то, что схема инициализирована
+func (s *OrderService) CheckDB(ctx context.Context) error {
+	if !s.db.Initialized() {
+		return errors.New("database migrations are not applied")
+	}
+	if err := s.db.Ping(ctx); err != nil {
+		return fmt.Errorf("database ping failed: %w", err)
+	}
 	return nil
 }
+
+func (s *OrderService) PoolStats() database.PoolStats {
+	return s.db.PoolStats()
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"order-service/internal/errs"
+	"order-service/internal/models"
+)
+
+type WarmupStrategy string
+
+const (
+	// Не прогревать кэш
+	WarmupNone WarmupStrategy = "none"
+	// Limit самых новых заказов
+	WarmupLatest WarmupStrategy = "latest"
+	// Все заказы за последние Days дней
+	WarmupRecent WarmupStrategy = "recent"
+	// Limit самых запрашиваемых заказов по статистике обращений
+	WarmupTop WarmupStrategy = "top"
+	// Все заказы
+	WarmupAll WarmupStrategy = "all"
+)
+
+func ParseWarmupStrategy(value string) (WarmupStrategy, error) {
+	switch s := WarmupStrategy(value); s {
+	case WarmupNone, WarmupLatest, WarmupRecent, WarmupTop, WarmupAll:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown warm-up strategy %q", value)
+}
+
+type WarmupConfig struct {
+	Strategy  WarmupStrategy
+	Days      int
+	Limit     int
+	BatchSize int
+}
+
+func DefaultWarmupConfig() WarmupConfig {
+	return WarmupConfig{
+		Strategy:  WarmupLatest,
+		Days:      7,
+		Limit:     100,
+		BatchSize: 500,
+	}
+}
+
+type WarmupProgress struct {
+	Strategy   WarmupStrategy `json:"strategy"`
+	Running    bool           `json:"running"`
+	Done       bool           `json:"done"`
+	Loaded     int            `json:"loaded"`
+	Error      string         `json:"error,omitempty"`
+	StartedAt  *time.Time     `json:"started_at,omitempty"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+}
+
+type warmupState struct {
+	mu       sync.RWMutex
+	progress WarmupProgress
+	cfg      WarmupConfig
+	// Первый прогрев завершен. Повторные прогревы флаг не сбрасывают
+	initialDone bool
+}
+
+func (w *warmupState) update(fn func(p *WarmupProgress)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	fn(&w.progress)
+}
+
+func (w *warmupState) get() WarmupProgress {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.progress
+}
+
+// ErrWarmupRunning - прогрев уже идет, повторный запуск отклонен
+var ErrWarmupRunning = errs.New(errs.Conflict, "cache warm-up is already running")
+
+// StartWarmup прогревает кэш в фоне. WarmupDone возвращает false только до
+// завершения первого прогрева: перезагрузка кэша не выводит сервис из балансировки
+func (s *OrderService) StartWarmup(ctx context.Context, cfg WarmupConfig) error {
+	started := time.Now()
+	running := false
+	s.warmup.update(func(p *WarmupProgress) {
+		if p.Running {
+			running = true
+			return
+		}
+		*p = WarmupProgress{Strategy: cfg.Strategy, Running: true, StartedAt: &started}
+		s.warmup.cfg = cfg
+	})
+	if running {
+		return ErrWarmupRunning
+	}
+
+	go func() {
+		// Сначала сверяем с БД то, что уже есть в кэше (например, из снимка), затем догружаем
+		err := errors.Join(
+			s.reconcileCache(ctx, cfg.BatchSize),
+			s.LoadCacheFromDB(ctx, cfg),
+		)
+		if err != nil {
+			slog.WarnContext(ctx, "cache warm-up failed", "error", err)
+		}
+
+		s.warmup.update(func(p *WarmupProgress) {
+			p.Running = false
+			// Ошибка прогрева не должна навсегда выводить сервис из строя:
+			// кэш догреется по промахам
+			p.Done = true
+			s.warmup.initialDone = true
+			finished := time.Now()
+			p.FinishedAt = &finished
+			if err != nil {
+				p.Error = err.Error()
+			}
+		})
+	}()
+
+	return nil
+}
+
+// ReloadCache повторно загружает кэш из БД с настройками последнего прогрева
+func (s *OrderService) ReloadCache(ctx context.Context) error {
+	s.warmup.mu.RLock()
+	cfg := s.warmup.cfg
+	s.warmup.mu.RUnlock()
+
+	if cfg.Strategy == "" {
+		cfg = DefaultWarmupConfig()
+	}
+	return s.StartWarmup(ctx, cfg)
+}
+
+func (s *OrderService) WarmupProgress() WarmupProgress {
+	return s.warmup.get()
+}
+
+// WarmupDone сообщает, завершен ли первый прогрев кэша
+func (s *OrderService) WarmupDone() bool {
+	s.warmup.mu.RLock()
+	defer s.warmup.mu.RUnlock()
+	return s.warmup.initialDone
+}
+
+// LoadCacheFromDB потоково загружает заказы в кэш пачками по cfg.BatchSize
+func (s *OrderService) LoadCacheFromDB(ctx context.Context, cfg WarmupConfig) error {
+	var stream func(collect func(*models.Order) error) error
+	switch cfg.Strategy {
+	case WarmupNone:
+		return nil
+	case WarmupLatest:
+		stream = func(collect func(*models.Order) error) error {
+			return s.db.StreamOrders(ctx, models.OrderFilter{Limit: cfg.Limit}, collect)
+		}
+	case WarmupRecent:
+		from := time.Now().AddDate(0, 0, -cfg.Days)
+		stream = func(collect func(*models.Order) error) error {
+			return s.db.StreamOrders(ctx, models.OrderFilter{CreatedFrom: from}, collect)
+		}
+	case WarmupTop:
+		stream = func(collect func(*models.Order) error) error {
+			return s.db.StreamTopAccessedOrders(ctx, cfg.Limit, collect)
+		}
+	case WarmupAll:
+		stream = func(collect func(*models.Order) error) error {
+			return s.db.StreamOrders(ctx, models.OrderFilter{}, collect)
+		}
+	default:
+		return fmt.Errorf("unknown warm-up strategy %q", cfg.Strategy)
+	}
+	return s.loadCache(ctx, cfg, stream)
+}
+
+// loadCache складывает в кэш заказы, которые stream передает в collect.
+// Заказы, измененные во время чтения, пропускаются: их уже вытеснило
+// уведомление, и прочитанная версия может быть устаревшей
+func (s *OrderService) loadCache(ctx context.Context, cfg WarmupConfig, stream func(collect func(*models.Order) error) error) error {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultWarmupConfig().BatchSize
+	}
+
+	started := time.Now()
+	loaded := 0
+	batch := make([]models.Order, 0, cfg.BatchSize)
+
+	sc := s.fills.beginScan()
+	defer s.fills.endScan(sc)
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		loaded += s.fills.setScanned(sc, batch, s.cache.LoadFromSlice)
+		batch = batch[:0]
+
+		n := loaded
+		s.warmup.update(func(p *WarmupProgress) { p.Loaded = n })
+		slog.InfoContext(ctx, "cache warm-up progress", "strategy", cfg.Strategy, "loaded", n)
+	}
+
+	collect := func(order *models.Order) error {
+		batch = append(batch, *order)
+		if len(batch) >= cfg.BatchSize {
+			flush()
+		}
+		return ctx.Err()
+	}
+
+	err := stream(collect)
+	flush()
+
+	if err != nil {
+		return fmt.Errorf("failed to load orders from DB: %w", err)
+	}
+
+	slog.InfoContext(ctx, "cache warm-up finished", "strategy", cfg.Strategy, "loaded", loaded, "duration", time.Since(started).Round(time.Millisecond).String())
+	return nil
+}
+
+// reconcileCache перечитывает из БД все заказы, уже лежащие в кэше: обновляет
+// измененные и удаляет те, которых в БД больше нет
+func (s *OrderService) reconcileCache(ctx context.Context, batchSize int) error {
+	cached := s.cache.GetAll()
+	if len(cached) == 0 {
+		return nil
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultWarmupConfig().BatchSize
+	}
+
+	ids := make([]string, 0, len(cached))
+	for _, order := range cached {
+		ids = append(ids, order.OrderID)
+	}
+
+	removed := 0
+	for start := 0; start < len(ids); start += batchSize {
+		batch := ids[start:min(start+batchSize, len(ids))]
+
+		readStarted := s.fills.begin(batch...)
+		orders, err := s.db.GetOrders(ctx, batch)
+		if err != nil {
+			s.fills.end(batch...)
+			return fmt.Errorf("failed to reconcile cache with DB: %w", err)
+		}
+
+		fresh := make(map[string]bool, len(orders))
+		for _, order := range orders {
+			// Заказ, измененный во время чтения, уже вытеснен уведомлением
+			s.fills.set(order.OrderID, readStarted, func() { s.cache.Set(order) })
+			fresh[order.OrderID] = true
+		}
+		s.fills.end(batch...)
+		for _, id := range batch {
+			if !fresh[id] {
+				s.cache.Delete(id)
+				removed++
+			}
+		}
+	}
+
+	slog.InfoContext(ctx, "cache reconciled with DB", "orders", len(ids), "removed", removed)
+	return nil
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"order-service/internal/cache"
+	"order-service/internal/models"
+)
+
+func waitWarmup(t *testing.T, s *OrderService) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for s.WarmupProgress().Running {
+		if time.Now().After(deadline) {
+			t.Fatal("warm-up did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestReloadKeepsReadiness проверяет, что только первый прогрев влияет на
+// готовность, а перезагрузка кэша администратором - нет
+func TestReloadKeepsReadiness(t *testing.T) {
+	s := NewOrderService(nil, cache.NewCache())
+	ctx := context.Background()
+	cfg := WarmupConfig{Strategy: WarmupNone}
+
+	if s.WarmupDone() {
+		t.Fatal("warm-up reported done before it started")
+	}
+	if err := s.StartWarmup(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	waitWarmup(t, s)
+	if !s.WarmupDone() {
+		t.Fatal("initial warm-up is not done")
+	}
+
+	// Прогресс перезагрузки сбрасывается, готовность остается
+	if err := s.ReloadCache(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !s.WarmupDone() {
+		t.Fatal("cache reload took the service out of readiness")
+	}
+	waitWarmup(t, s)
+}
+
+// TestWarmupSkipsOrdersChangedWhileStreaming воспроизводит гонку: строка
+// прочитана прогревом, затем пришло уведомление об ее изменении, и только
+// после этого пачка записывается в кэш
+func TestWarmupSkipsOrdersChangedWhileStreaming(t *testing.T) {
+	s := NewOrderService(nil, cache.NewCache())
+	cfg := WarmupConfig{Strategy: WarmupAll, BatchSize: 10}
+
+	err := s.loadCache(context.Background(), cfg, func(collect func(*models.Order) error) error {
+		for _, id := range []string{"o1", "o2"} {
+			if err := collect(&models.Order{OrderID: id, Locale: "stale"}); err != nil {
+				return err
+			}
+		}
+		s.evictChanged("o1")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.cache.Contains("o1") {
+		t.Fatal("order changed during warm-up was stored with its stale version")
+	}
+	if !s.cache.Contains("o2") {
+		t.Fatal("unchanged order was not loaded")
+	}
+	if got := s.WarmupProgress().Loaded; got != 1 {
+		t.Fatalf("loaded = %d, want 1", got)
+	}
+	if len(s.fills.scans) != 0 {
+		t.Fatal("scan was not released")
+	}
+}
+
+func TestWarmupAfterCacheReset(t *testing.T) {
+	s := NewOrderService(nil, cache.NewCache())
+	err := s.loadCache(context.Background(), WarmupConfig{Strategy: WarmupAll}, func(collect func(*models.Order) error) error {
+		collect(&models.Order{OrderID: "o1"})
+		// Слушатель переподключился и сбросил кэш
+		s.fills.invalidateAll()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.cache.Contains("o1") {
+		t.Fatal("order read before the cache reset was stored")
+	}
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"order-service/internal/auth"
+	"order-service/internal/errs"
+	"order-service/internal/models"
+)
+
+// OrderChange - изменение заказа для подписчиков WatchOrders. Order равен nil, если заказ удален
+type OrderChange struct {
+	OrderID string
+	Order   *models.Order
+}
+
+// Сколько изменений может накопиться у подписчика, прежде чем его отключат
+const watchBuffer = 256
+
+// Сколько уведомлений может ждать чтения из БД для рассылки подписчикам
+const watchQueue = 1024
+
+// ErrWatchOverflow - подписчик не успевал забирать изменения и был отключен
+var ErrWatchOverflow = errs.New(errs.Unavailable, "order change subscriber is too slow, resubscribe")
+
+// watchers рассылает изменения заказов подписчикам. Медленный подписчик не
+// задерживает остальных: при переполнении буфера его канал закрывается,
+// а WatchOrders возвращает ErrWatchOverflow
+type watchers struct {
+	mu   sync.Mutex
+	subs map[chan OrderChange]struct{}
+}
+
+func (w *watchers) subscribe() chan OrderChange {
+	ch := make(chan OrderChange, watchBuffer)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.subs == nil {
+		w.subs = make(map[chan OrderChange]struct{})
+	}
+	w.subs[ch] = struct{}{}
+	return ch
+}
+
+func (w *watchers) unsubscribe(ch chan OrderChange) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.subs[ch]; ok {
+		delete(w.subs, ch)
+		close(ch)
+	}
+}
+
+func (w *watchers) active() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.subs) > 0
+}
+
+func (w *watchers) publish(change OrderChange) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for ch := range w.subs {
+		select {
+		case ch <- change:
+		default:
+			slog.Warn("order change subscriber is too slow, disconnecting")
+			delete(w.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// closeAll отключает всех подписчиков, WatchOrders у них вернет ErrWatchOverflow
+func (w *watchers) closeAll() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for ch := range w.subs {
+		delete(w.subs, ch)
+		close(ch)
+	}
+}
+
+// queueChange передает уведомление об изменении в publishChanges, не блокируя
+// слушателя уведомлений. Если очередь переполнена, подписчики отключаются:
+// лучше переподписка, чем незаметно пропущенные изменения
+func (s *OrderService) queueChange(queue chan<- string, orderID string) {
+	if !s.watchers.active() {
+		return
+	}
+	select {
+	case queue <- orderID:
+	default:
+		slog.Warn("order change queue is full, disconnecting watchers", "order_id", orderID)
+		s.watchers.closeAll()
+	}
+}
+
+// publishChanges рассылает изменения из queue подписчикам до закрытия queue
+func (s *OrderService) publishChanges(ctx context.Context, queue <-chan string) {
+	for orderID := range queue {
+		s.publishChange(ctx, orderID)
+	}
+}
+
+// publishChange читает актуальное состояние заказа и рассылает его подписчикам.
+// Без подписчиков в БД не ходит
+func (s *OrderService) publishChange(ctx context.Context, orderID string) {
+	if !s.watchers.active() {
+		return
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	order, err := s.db.GetOrder(fetchCtx, orderID)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to load changed order for watchers", "order_id", orderID, "error", err)
+		return
+	}
+	s.watchers.publish(OrderChange{OrderID: orderID, Order: order})
+}
+
+// WatchOrders передает в fn изменения заказов, доступных вызывающему, до отмены ctx.
+// clientID, отличный от 0, оставляет изменения только этого клиента. Удаления
+// видят только подписчики без ограничений по клиентам: по удаленному заказу
+// нельзя проверить, чей он был
+func (s *OrderService) WatchOrders(ctx context.Context, clientID int64, fn func(OrderChange) error) error {
+	ctx, span := tracer.Start(ctx, "OrderService.WatchOrders")
+	defer span.End()
+
+	principal := auth.FromContext(ctx)
+	if clientID != 0 && !principal.CanAccessClient(clientID) {
+		return fmt.Errorf("%w: client_id %d", ErrForbidden, clientID)
+	}
+	_, restricted := principal.AllowedClients()
+
+	ch := s.watchers.subscribe()
+	defer s.watchers.unsubscribe(ch)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case change, ok := <-ch:
+			if !ok {
+				return ErrWatchOverflow
+			}
+
+			if change.Order == nil {
+				if restricted || clientID != 0 {
+					continue
+				}
+			} else {
+				if !principal.CanAccessClient(change.Order.ClientID) || (clientID != 0 && change.Order.ClientID != clientID) {
+					continue
+				}
+				// Заказ общий для всех подписчиков, маскируем свою копию
+				change.Order = change.Order.Clone()
+				s.mask.Apply(ctx, change.Order)
+			}
+
+			if err := fn(change); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"order-service/internal/cache"
+	"order-service/internal/models"
+)
+
+// TestWatchOverflow проверяет, что отстающий подписчик получает ErrWatchOverflow,
+// а не тихо закрытый поток
+func TestWatchOverflow(t *testing.T) {
+	s := NewOrderService(nil, cache.NewCache())
+	release := make(chan struct{})
+	result := make(chan error, 1)
+	go func() {
+		result <- s.WatchOrders(context.Background(), 0, func(OrderChange) error {
+			<-release
+			return nil
+		})
+	}()
+	for !s.watchers.active() {
+		time.Sleep(time.Millisecond)
+	}
+
+	for range watchBuffer + 2 {
+		s.watchers.publish(OrderChange{OrderID: "o1", Order: &models.Order{OrderID: "o1"}})
+	}
+	close(release)
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, ErrWatchOverflow) {
+			t.Fatalf("WatchOrders returned %v, want ErrWatchOverflow", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("slow subscriber was not disconnected")
+	}
+}
+
+// TestQueueChangeDoesNotBlock проверяет, что переполненная очередь рассылки
+// не задерживает слушателя уведомлений, а отключает подписчиков
+func TestQueueChangeDoesNotBlock(t *testing.T) {
+	s := NewOrderService(nil, cache.NewCache())
+	ch := s.watchers.subscribe()
+	queue := make(chan string, 1)
+
+	done := make(chan struct{})
+	go func() {
+		s.queueChange(queue, "o1")
+		s.queueChange(queue, "o2")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("queueChange blocked on a full queue")
+	}
+
+	if _, ok := <-ch; ok {
+		t.Fatal("subscriber was not disconnected after queue overflow")
+	}
+}
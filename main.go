@@ -2,67 +2,186 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"io/fs"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
 	"order-service/internal/api"
+	"order-service/internal/auth"
 	"order-service/internal/cache"
+	"order-service/internal/config"
 	"order-service/internal/database"
+	"order-service/internal/logging"
+	"order-service/internal/metrics"
+	"order-service/internal/pii"
+	"order-service/internal/ratelimit"
+	"order-service/internal/rpc"
 	"order-service/internal/service"
+	"order-service/internal/telemetry"
 )
 
+// Версия сборки, задается через -ldflags "-X main.version=..."
+var version = "dev"
+
 func main() {
-	// Загружаем переменные окружения
-	if err := godotenv.Load(); err != nil {
-		log.Printf("Warning: .env file not found")
+	// Загружаем переменные окружения, уже заданные переменные .env не перекрывает
+	envErr := godotenv.Load()
+
+	// Подкоманды CLI
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "export":
+			runExport(os.Args[2:], envErr)
+			return
+		case "apikey":
+			runAPIKey(os.Args[2:])
+			return
+		case "reencrypt":
+			runReencrypt(os.Args[2:], envErr)
+			return
+		case "gdpr":
+			runGDPR(os.Args[2:], envErr)
+			return
+		}
 	}
 
-	// Подключаемся к PostgreSQL
-	connString := fmt.Sprintf(
-		"postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"), os.Getenv("DB_HOST"), os.Getenv("DB_PORT"), os.Getenv("DB_NAME"),
-	)
+	printConfig := flag.Bool("print-config", false, "print effective config with secrets redacted and exit")
+	cfg := loadConfig(flag.CommandLine, os.Args[1:], envErr)
+	if *printConfig {
+		fmt.Print(cfg)
+		return
+	}
+
+	// Значения, которые config не может проверить сам, разбираются до подключения к БД
+	warmup, err := warmupConfig(cfg.Cache)
+	if err != nil {
+		fatal("invalid config", "error", err)
+	}
+	unmasked, err := unmaskedRoles(cfg.PII)
+	if err != nil {
+		fatal("invalid config", "error", err)
+	}
 
 	ctx := context.Background()
-	pool, err := pgxpool.New(ctx, connString)
+
+	shutdownTracing, err := telemetry.Setup(ctx, cfg.Tracing.Exporter)
 	if err != nil {
-		log.Fatalf("Unable to create connection pool: %v", err)
+		fatal("failed to set up tracing", "error", err)
 	}
-	defer pool.Close()
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			slog.Warn("failed to flush traces", "error", err)
+		}
+	}()
 
-	// Проверяем соединение
-	if err := pool.Ping(ctx); err != nil {
-		log.Fatalf("Unable to connect to database: %v", err)
+	pool, err := connectDB(ctx, cfg.Database)
+	if err != nil {
+		fatal("failed to connect to PostgreSQL", "error", err)
 	}
-	log.Println("Connected to PostgreSQL")
+	defer pool.Close()
+	slog.Info("connected to PostgreSQL")
 
 	// Инициализируем БД
 	db := database.NewPostgresBase(pool)
+	keyring := newKeyring(cfg.PII)
+	db.SetKeyring(keyring)
 	if err := db.InitDB(ctx); err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		fatal("failed to initialize database", "error", err)
 	}
-	log.Println("Database initialized")
+	slog.Info("database initialized")
 
 	// Инициализируем кэш
-	cache := cache.NewCache()
+	cache := cache.NewCacheWithShards(cfg.Cache.Shards)
+	cache.SetNegativeTTL(cfg.Cache.NegativeTTL)
+	if keyring != nil {
+		// В снимке лежат расшифрованные данные доставки, шифруем его теми же ключами
+		cache.SetSnapshotCipher(keyring)
+	}
+
+	// Поднимаем кэш из снимка на диске, прогрев затем сверит его с БД
+	snapshotPath := cfg.Cache.SnapshotPath
+	if snapshotPath != "" {
+		n, savedAt, err := cache.LoadSnapshot(snapshotPath)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			slog.Info("cache snapshot not found, starting cold", "path", snapshotPath)
+		case err != nil:
+			slog.Warn("failed to load cache snapshot", "path", snapshotPath, "error", err)
+		default:
+			slog.Info("cache snapshot loaded", "orders", n, "saved_at", savedAt)
+		}
+	}
+
+	metrics.Register(pool, cache)
 
 	// Инициализируем сервис
 	orderService := service.NewOrderService(db, cache)
+	orderService.SetMaskPolicy(pii.NewMaskPolicy(unmasked))
+
+	// Фоновые задачи живут до остановки сервера
+	bgCtx, stopBackground := context.WithCancel(ctx)
+	defer stopBackground()
 
-	// Загружаем данные из БД в кэш
-	if err := orderService.LoadCacheFromDB(ctx); err != nil {
-		log.Printf("Warning: failed to load cache from DB: %v", err)
+	// Прогреваем кэш в фоне, не блокируя старт
+	if err := orderService.StartWarmup(bgCtx, warmup); err != nil {
+		fatal("failed to start cache warm-up", "error", err)
 	}
 
-	handler := api.NewHandler(orderService)
+	// Сбрасываем кэш при изменениях заказов на других репликах
+	go orderService.RunCacheInvalidation(bgCtx)
+
+	// Периодически сохраняем снимок кэша, последний - при остановке
+	snapshotSaved := make(chan struct{})
+	if snapshotPath != "" {
+		go func() {
+			cache.RunSnapshots(bgCtx, snapshotPath, cfg.Cache.SnapshotInterval)
+			close(snapshotSaved)
+		}()
+	} else {
+		close(snapshotSaved)
+	}
+
+	// Статистика обращений для прогрева по стратегии top
+	statsFlushed := make(chan struct{})
+	go func() {
+		orderService.RunAccessStatsFlusher(bgCtx, cfg.Cache.AccessStatsFlushInterval)
+		close(statsFlushed)
+	}()
+
+	authenticator, err := auth.New(auth.Config{
+		APIKeysFile: cfg.Auth.APIKeysFile,
+		JWKSFile:    cfg.Auth.JWKSFile,
+		JWTIssuer:   cfg.Auth.JWTIssuer,
+		JWTAudience: cfg.Auth.JWTAudience,
+		// Запуск без аутентификации возможен только по явному AUTH_DISABLED
+		AllowOpen: cfg.Auth.Disabled,
+	})
+	if err != nil {
+		fatal("failed to set up authentication", "error", err)
+	}
+	if !authenticator.Enabled() {
+		slog.Warn("authentication is disabled by AUTH_DISABLED, API is open to anyone")
+	}
+
+	handler := api.NewHandler(orderService, authenticator, version)
+	limits := apiLimits(cfg)
+	handler.SetLimits(limits)
 
 	// Настраиваем роуты
 	mux := http.NewServeMux()
@@ -70,11 +189,21 @@ func main() {
 
 	// Запуск сервера
 	server := &http.Server{
-		Addr:         ":" + os.Getenv("SERVER_PORT"),
-		Handler:      mux,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
+		Handler:      api.RequestLogger(mux),
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+
+	// gRPC API на отдельном порту
+	var grpcServer *rpc.Server
+	if cfg.Server.GRPCPort > 0 {
+		grpcServer, err = startGRPC(cfg.Server, orderService, authenticator,
+			rpc.Limits{Rate: limits.DefaultRate, AuthFailureRate: limits.AuthFailureRate})
+		if err != nil {
+			fatal("could not start gRPC server", "port", cfg.Server.GRPCPort, "error", err)
+		}
 	}
 
 	// Канал для graceful shutdown
@@ -84,24 +213,194 @@ func main() {
 
 	go func() {
 		<-quit
-		log.Println("Server is shutting down...")
+		slog.Info("server is shutting down")
+
+		// Сначала снимаем готовность и даем балансировщику время это заметить
+		handler.SetShuttingDown()
+		time.Sleep(cfg.Server.ShutdownDelay)
 
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 		defer cancel()
 
 		if err := server.Shutdown(ctx); err != nil {
-			log.Fatalf("Could not gracefully shutdown the server: %v", err)
+			fatal("could not gracefully shutdown the server", "error", err)
+		}
+		if grpcServer != nil {
+			if err := grpcServer.Shutdown(ctx); err != nil {
+				slog.Warn("gRPC calls were interrupted on shutdown", "error", err)
+			}
 		}
 		close(done)
 	}()
 
-	log.Printf("Server starting on port %s", os.Getenv("SERVER_PORT"))
-	log.Println("Open http://localhost:" + os.Getenv("SERVER_PORT") + " in your browser")
+	tls := cfg.Server.TLSCertFile != ""
+	scheme := "http"
+	if tls {
+		scheme = "https"
+	}
+	slog.Info("server starting", "version", version, "port", cfg.Server.Port, "url", fmt.Sprintf("%s://localhost:%d", scheme, cfg.Server.Port))
 
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("Could not listen on %s: %v", os.Getenv("SERVER_PORT"), err)
+	if tls {
+		err = server.ListenAndServeTLS(cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
+		fatal("could not listen", "port", cfg.Server.Port, "error", err)
 	}
 
 	<-done
-	log.Println("Server stopped")
+	stopBackground()
+	<-statsFlushed
+	<-snapshotSaved
+	slog.Info("server stopped")
+}
+
+// fatal пишет ошибку в лог и завершает процесс
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// loadConfig собирает конфигурацию и настраивает по ней логирование
+func loadConfig(fs *flag.FlagSet, args []string, envErr error) *config.Config {
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		fatal("failed to load config", "error", err)
+	}
+
+	// Структурированные логи в stderr: stdout занят выгрузкой в CLI
+	if err := logging.Setup(os.Stderr, cfg.Log.Level, cfg.Log.Format); err != nil {
+		fatal("invalid logging config", "error", err)
+	}
+	if envErr != nil {
+		slog.Warn(".env file not found")
+	}
+	slog.Debug("effective config\n" + cfg.String())
+
+	return cfg
+}
+
+// startGRPC запускает gRPC-сервер в фоне. TLS включается тем же сертификатом, что и у HTTP
+func startGRPC(cfg config.ServerConfig, orderService *service.OrderService, authenticator *auth.Authenticator, limits rpc.Limits) (*rpc.Server, error) {
+	var opts []grpc.ServerOption
+	if cfg.TLSCertFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.GRPCPort))
+	if err != nil {
+		return nil, err
+	}
+
+	server := rpc.NewServer(orderService, authenticator, limits, opts...)
+	go func() {
+		if err := server.Serve(lis); err != nil {
+			fatal("gRPC server failed", "port", cfg.GRPCPort, "error", err)
+		}
+	}()
+	slog.Info("gRPC server starting", "port", cfg.GRPCPort)
+	return server, nil
+}
+
+// warmupConfig переводит настройки прогрева в формат сервиса
+func warmupConfig(cfg config.CacheConfig) (service.WarmupConfig, error) {
+	strategy, err := service.ParseWarmupStrategy(cfg.WarmupStrategy)
+	if err != nil {
+		return service.WarmupConfig{}, fmt.Errorf("cache.warmup_strategy: %w", err)
+	}
+	return service.WarmupConfig{
+		Strategy:  strategy,
+		Days:      cfg.WarmupDays,
+		Limit:     cfg.WarmupLimit,
+		BatchSize: cfg.WarmupBatch,
+	}, nil
+}
+
+// unmaskedRoles разбирает роли, которым персональные данные отдаются без маскирования
+func unmaskedRoles(cfg config.PIIConfig) ([]auth.Role, error) {
+	var roles []auth.Role
+	for _, name := range strings.Split(cfg.UnmaskedRoles, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		role, err := auth.ParseRole(name)
+		if err != nil {
+			return nil, fmt.Errorf("pii.unmasked_roles: %w", err)
+		}
+		roles = append(roles, role)
+	}
+	return roles, nil
+}
+
+// apiLimits переводит настройки ограничений в формат API. Значения уже проверены config.Validate
+func apiLimits(cfg *config.Config) api.Limits {
+	limits := api.Limits{
+		MaxBodyBytes:   int64(cfg.Server.MaxBodyBytes),
+		TrustProxy:     cfg.RateLimit.TrustProxy,
+		TrustedProxies: cfg.RateLimit.TrustedProxies,
+		WriteTimeout:   cfg.Server.WriteTimeout,
+	}
+	if !cfg.RateLimit.Enabled {
+		return limits
+	}
+
+	def, _ := ratelimit.ParseLimit(cfg.RateLimit.Default)
+	limits.DefaultRate = &def
+	authFailures, _ := ratelimit.ParseLimit(cfg.RateLimit.AuthFailures)
+	limits.AuthFailureRate = &authFailures
+	limits.RouteRates = make(map[string]ratelimit.Limit, len(cfg.RateLimit.Routes))
+	for route, value := range cfg.RateLimit.Routes {
+		limits.RouteRates[route], _ = ratelimit.ParseLimit(value)
+	}
+	return limits
+}
+
+// newKeyring создает набор ключей шифрования персональных данных, nil - шифрование выключено
+func newKeyring(cfg config.PIIConfig) *pii.Keyring {
+	keyring, err := pii.NewKeyring(cfg.EncryptionKeys, cfg.ActiveKey, cfg.BlindIndexKey)
+	if err != nil {
+		fatal("invalid PII encryption config", "error", err)
+	}
+	if keyring == nil {
+		slog.Warn("PII encryption is disabled: delivery data is stored in plaintext")
+	} else {
+		slog.Info("PII encryption enabled", "active_key", keyring.ActiveKeyID())
+	}
+	return keyring
+}
+
+func connectDB(ctx context.Context, cfg config.DatabaseConfig) (*pgxpool.Pool, error) {
+	// Подключаемся к PostgreSQL
+	poolConfig, err := pgxpool.ParseConfig(cfg.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("invalid database config: %w", err)
+	}
+	poolConfig.MaxConns = int32(cfg.MaxConns)
+	poolConfig.MinConns = int32(cfg.MinConns)
+	if cfg.MaxConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = cfg.MaxConnLifetime
+	}
+	if cfg.MaxConnIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = cfg.MaxConnIdleTime
+	}
+	// Span на каждый SQL-запрос
+	poolConfig.ConnConfig.Tracer = database.QueryTracer{}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create connection pool: %w", err)
+	}
+
+	// Проверяем соединение
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("unable to connect to database: %w", err)
+	}
+
+	return pool, nil
 }
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"order-service/internal/database"
+)
+
+// runReencrypt перешифровывает персональные данные доставки активным ключом.
+// Нужен после ротации ключа (старый ключ убирается из PII_ENCRYPTION_KEYS только
+// после него) и для шифрования данных, записанных до включения шифрования:
+//
+//	order-service reencrypt -batch 500
+func runReencrypt(args []string, envErr error) {
+	fs := flag.NewFlagSet("reencrypt", flag.ExitOnError)
+	batch := fs.Int("batch", 500, "rows per transaction")
+	cfg := loadConfig(fs, args, envErr)
+
+	if *batch <= 0 {
+		fatal("-batch must be positive")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	pool, err := connectDB(ctx, cfg.Database)
+	if err != nil {
+		fatal("failed to connect to PostgreSQL", "error", err)
+	}
+	defer pool.Close()
+
+	keyring := newKeyring(cfg.PII)
+	if keyring == nil {
+		fatal("PII_ENCRYPTION_KEYS must be set to re-encrypt delivery data")
+	}
+
+	db := database.NewPostgresBase(pool)
+	db.SetKeyring(keyring)
+	// Миграция добавляет колонку слепого индекса
+	if err := db.InitDB(ctx); err != nil {
+		fatal("failed to initialize database", "error", err)
+	}
+
+	started := time.Now()
+	updated, err := db.ReencryptDelivery(ctx, *batch)
+	if err != nil {
+		fatal("re-encryption failed", "updated", updated, "error", err)
+	}
+
+	slog.Info("delivery data re-encrypted", "updated", updated, "active_key", keyring.ActiveKeyID(), "duration", time.Since(started).Round(time.Millisecond).String())
+}
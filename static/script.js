@@ -110,7 +110,7 @@ async function getOrder() {
     hideResult();
 
     try {
-        const response = await fetch(`/api/order?order_id=${encodeURIComponent(orderId)}`);
+        const response = await fetch(`/api/v1/orders/${encodeURIComponent(orderId)}`);
 
         if (response.status === 404) {
             showError('Заказ не найден');
@@ -157,7 +157,7 @@ async function createOrder() {
     hideError();
 
     try {
-        const response = await fetch('/api/order', {
+        const response = await fetch('/api/v1/orders', {
             method: 'POST',
             headers: {
                 'Content-Type': 'application/json',
@@ -166,8 +166,9 @@ async function createOrder() {
         });
 
         if (!response.ok) {
-            const errorText = await response.text();
-            throw new Error(errorText || `Ошибка: ${response.status}`);
+            // Ошибки API приходят в формате application/problem+json
+            const problem = await response.json().catch(() => null);
+            throw new Error(problem?.detail || `Ошибка: ${response.status}`);
         }
 
         const result = await response.json();
@@ -377,7 +378,7 @@ document.addEventListener('DOMContentLoaded', function () {
     console.log('Page loaded, JavaScript is working!');
 
     // Загружаем тестовый заказ
-    fetch('/api/order?order_id=test1234567890')
+    fetch('/api/v1/orders/test1234567890')
         .then(response => {
             if (response.ok) return response.json();
             throw new Error('Failed to fetch test order');